@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"transaction-logger/internal/chain"
+	"transaction-logger/internal/store"
+)
+
+const chainUsage = "usage: chain verify [org_id...] | checkpoint"
+
+// errChainBroken makes the command exit non-zero when any chain fails verification
+var errChainBroken = errors.New("hash chain verification failed")
+
+// runChain implements the chain subcommand
+func runChain(ctx context.Context, chains store.ChainStore, signer *chain.Signer, args []string) error {
+	if len(args) == 0 {
+		return errors.New(chainUsage)
+	}
+
+	switch args[0] {
+	case "verify":
+		orgIDs := args[1:]
+		if len(orgIDs) == 0 {
+			heads, err := chains.ListChainHeads(ctx)
+			if err != nil {
+				return err
+			}
+			for _, head := range heads {
+				orgIDs = append(orgIDs, head.OrganizationID)
+			}
+		}
+
+		broken := false
+		for _, orgID := range orgIDs {
+			report, err := chain.Verify(ctx, chains, signer, orgID)
+			if err != nil {
+				return err
+			}
+			if report.Valid {
+				fmt.Printf("ok      %-40s %d links, %d checkpoints\n", orgID, report.Links, report.Checkpoints)
+			} else {
+				broken = true
+				b := report.Broken
+				fmt.Printf("BROKEN  %-40s seq %d %s: %s\n", orgID, b.Seq, b.TransactionID, b.Reason)
+			}
+			if report.UnverifiedCheckpoints > 0 {
+				fmt.Printf("        %d checkpoints were signed with another key and not checked\n", report.UnverifiedCheckpoints)
+			}
+		}
+		if broken {
+			return errChainBroken
+		}
+		return nil
+
+	case "checkpoint":
+		created, err := chain.Checkpoint(ctx, chains, signer)
+		fmt.Printf("created %d checkpoints\n", created)
+		return err
+	}
+
+	return fmt.Errorf("unknown chain command %q: %s", args[0], chainUsage)
+}
@@ -1,60 +1,327 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
 
 	"transaction-logger/internal/auth"
+	"transaction-logger/internal/chain"
 	"transaction-logger/internal/config"
 	"transaction-logger/internal/database"
 	"transaction-logger/internal/handlers"
+	"transaction-logger/internal/mail"
+	"transaction-logger/internal/store"
+	"transaction-logger/internal/store/memory"
+	"transaction-logger/internal/store/postgres"
+	"transaction-logger/migrations"
 )
 
 func main() {
 	// Load configuration
 	cfg := config.LoadConfig()
 
-	// Initialize database
-	db, err := database.NewDB(cfg)
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+	// "migrate up|down|status" and "chain verify|checkpoint" run instead of the server.
+	// Both work on the database, so they make no sense with in-memory storage.
+	var command string
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+	switch command {
+	case "":
+	case "migrate", "chain":
+		if cfg.StorageDriver != "postgres" {
+			log.Fatalf("The %s command requires STORAGE_DRIVER=postgres, not %q", command, cfg.StorageDriver)
+		}
+	default:
+		log.Fatalf("Unknown command %q: expected migrate or chain", command)
 	}
-	defer db.Close()
 
 	// Initialize auth package with config
-	auth.Init(cfg)
+	if err := auth.Init(cfg); err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+	if cfg.JWTKeysDir == "" {
+		log.Printf("JWT_KEYS_DIR is not set; signing keys are kept in memory and tokens do not survive a restart")
+	}
+
+	signer, err := chain.LoadSigner(cfg.ChainSigningKeyFile)
+	if err != nil {
+		log.Fatalf("Failed to load checkpoint signing key: %v", err)
+	}
+	if cfg.ChainSigningKeyFile == "" {
+		log.Printf("CHAIN_SIGNING_KEY_FILE is not set; hash chain checkpoints cannot be verified after a restart")
+	}
+
+	var st store.Store
+	switch cfg.StorageDriver {
+	case "memory":
+		log.Printf("Using in-memory storage; data is lost on restart")
+		st = memory.New(cfg.IdempotencyKeyTTL)
+	case "postgres":
+		db, err := database.NewDB(cfg)
+		if err != nil {
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
+		defer db.Close()
+
+		migrator, err := database.NewMigrator(db.DB, migrations.FS)
+		if err != nil {
+			log.Fatalf("Failed to load migrations: %v", err)
+		}
+
+		switch command {
+		case "migrate":
+			if err := runMigrate(migrator, os.Args[2:]); err != nil {
+				log.Fatalf("Migration failed: %v", err)
+			}
+			return
+		case "chain":
+			// Leave the schema as it is; "migrate up" is the way to change it
+			if err := runChain(context.Background(), postgres.New(db.DB, cfg.IdempotencyKeyTTL), signer, os.Args[2:]); err != nil {
+				log.Fatalf("Chain command failed: %v", err)
+			}
+			return
+		}
+
+		// Apply pending migrations
+		applied, err := migrator.Up()
+		if err != nil {
+			log.Fatalf("Failed to migrate database schema: %v", err)
+		}
+		for _, m := range applied {
+			log.Printf("Applied migration %03d_%s", m.Version, m.Name)
+		}
+
+		st = postgres.New(db.DB, cfg.IdempotencyKeyTTL)
+	default:
+		log.Fatalf("Unknown STORAGE_DRIVER %q: expected postgres or memory", cfg.StorageDriver)
+	}
+
+	// Link transactions stored before the hash chain existed
+	if linked, err := st.LinkTransactions(context.Background()); err != nil {
+		log.Fatalf("Failed to link transactions into hash chains: %v", err)
+	} else if linked > 0 {
+		log.Printf("Linked %d transactions into hash chains", linked)
+	}
+
+	// Reject access tokens revoked by logout
+	auth.SetDenylist(st)
+
+	var limits store.RateLimitStore
+	switch cfg.RateLimitStore {
+	case "memory":
+		// A store of its own keeps buckets in this instance even when data is in Postgres
+		limits = memory.New(0)
+	case "postgres":
+		if cfg.StorageDriver != "postgres" {
+			log.Fatalf("RATE_LIMIT_STORE=postgres requires STORAGE_DRIVER=postgres")
+		}
+		limits = st
+	default:
+		log.Fatalf("Unknown RATE_LIMIT_STORE %q: expected memory or postgres", cfg.RateLimitStore)
+	}
+	// A bucket left alone for its longest period is full again and can be forgotten
+	rateLimitIdle := max(cfg.RateLimitAPI.Per, cfg.RateLimitAuth.Per, cfg.RateLimitGenerateSample.Per, cfg.RateLimitImport.Per, cfg.RateLimitExport.Per)
 
-	// Initialize database schema
-	if err := db.InitSchema(); err != nil {
-		log.Fatalf("Failed to initialize database schema: %v", err)
+	// Periodically purge expired idempotency keys, tokens, login failures and rate limits
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := st.PurgeIdempotencyKeys(context.Background()); err != nil {
+				log.Printf("Failed to purge idempotency keys: %v", err)
+			}
+			if _, err := st.PurgeExpiredTokens(context.Background()); err != nil {
+				log.Printf("Failed to purge expired tokens: %v", err)
+			}
+			if _, err := st.PurgeLoginAttempts(context.Background(), time.Now().Add(-handlers.LoginFailureWindow)); err != nil {
+				log.Printf("Failed to purge login attempts: %v", err)
+			}
+			if _, err := limits.PurgeRateLimits(context.Background(), time.Now().Add(-rateLimitIdle)); err != nil {
+				log.Printf("Failed to purge rate limits: %v", err)
+			}
+		}
+	}()
+
+	// Periodically sign checkpoints of the hash chains that have grown
+	if cfg.ChainCheckpointInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(cfg.ChainCheckpointInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				if _, err := chain.Checkpoint(context.Background(), st, signer); err != nil {
+					log.Printf("Failed to checkpoint hash chains: %v", err)
+				}
+			}
+		}()
+	}
+
+	// Replace the signing key once it reaches the rotation age
+	if cfg.JWTKeyRotation > 0 {
+		go func() {
+			ticker := time.NewTicker(min(cfg.JWTKeyRotation, time.Hour))
+			defer ticker.Stop()
+			for range ticker.C {
+				rotated, err := auth.Keys().RotateIfDue(cfg.JWTKeyRotation, time.Now())
+				if err != nil {
+					log.Printf("Failed to rotate signing key: %v", err)
+				} else if rotated {
+					log.Printf("Rotated signing key; new kid %s", auth.Keys().Active().ID)
+				}
+			}
+		}()
+	}
+
+	var mailer mail.Mailer
+	switch cfg.MailDriver {
+	case "log":
+		log.Printf("Using the log mail driver; emails are not delivered")
+		mailer = &mail.LogMailer{From: cfg.MailFrom, Dir: cfg.MailDir}
+	case "smtp":
+		mailer = mail.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	default:
+		log.Fatalf("Unknown MAIL_DRIVER %q: expected smtp or log", cfg.MailDriver)
 	}
+	links := mail.Links{BaseURL: cfg.AppBaseURL}
 
 	// Create router
 	router := mux.NewRouter()
+	router.Use(handlers.RequestIDMiddleware)
+	router.Use(handlers.ClientIPMiddleware(cfg.TrustProxyHeaders))
 
 	// Initialize handlers
-	transactionHandler := handlers.NewTransactionHandler(db.DB)
-	authHandler := handlers.NewAuthHandler(db.DB)
+	loginLimiter := handlers.NewLoginLimiter(st,
+		handlers.LockoutPolicy{Threshold: cfg.LoginLockoutThreshold, Lockout: cfg.LoginLockoutDuration},
+		handlers.LockoutPolicy{Threshold: cfg.LoginIPLockoutThreshold, Lockout: cfg.LoginLockoutDuration},
+	)
+	auditor := handlers.NewAuditor(st)
+	transactionHandler := handlers.NewTransactionHandler(st, auditor)
+	authHandler := handlers.NewAuthHandler(st, st, mailer, links, loginLimiter, auditor)
+	accountHandler := handlers.NewAccountHandler(st, auditor)
+	mfaHandler := handlers.NewMFAHandler(st, st, st, loginLimiter, auditor)
+	apiKeyHandler := handlers.NewAPIKeyHandler(st, auditor)
+	adminHandler := handlers.NewAdminHandler(st, st, st, st, loginLimiter, auditor)
+	organizationHandler := handlers.NewOrganizationHandler(st, st, mailer, links, auditor)
+	chainHandler := handlers.NewChainHandler(st, signer)
+	auditHandler := handlers.NewAuditHandler(st)
 
-	// API router with auth middleware
+	// Public signing keys for verifying access tokens
+	router.HandleFunc("/.well-known/jwks.json", handlers.JWKS).Methods("GET")
+
+	// Auth routes (public, rate limited per client IP)
+	authLimit := handlers.RateLimit(limits, "auth", cfg.RateLimitAuth)
+	router.Handle("/api/auth/register", authLimit(http.HandlerFunc(authHandler.Register))).Methods("POST")
+	router.Handle("/api/auth/login", authLimit(http.HandlerFunc(authHandler.Login))).Methods("POST")
+	router.Handle("/api/auth/refresh", authLimit(http.HandlerFunc(authHandler.Refresh))).Methods("POST")
+	router.Handle("/api/auth/mfa/verify", authLimit(http.HandlerFunc(mfaHandler.Verify))).Methods("POST")
+	router.Handle("/api/auth/verify-email", authLimit(http.HandlerFunc(authHandler.VerifyEmail))).Methods("POST")
+	router.Handle("/api/auth/password/forgot", authLimit(http.HandlerFunc(authHandler.ForgotPassword))).Methods("POST")
+	router.Handle("/api/auth/password/reset", authLimit(http.HandlerFunc(authHandler.ResetPassword))).Methods("POST")
+
+	// API router with auth middleware. It is registered after the public /api routes
+	// above, which its not-found handler would otherwise answer for.
 	apiRouter := router.PathPrefix("/api").Subrouter()
-	apiRouter.Use(handlers.AuthMiddleware)
+	apiRouter.Use(handlers.AuthMiddleware(st, st))
+	apiRouter.Use(handlers.RateLimit(limits, "api", cfg.RateLimitAPI))
+
+	// Logout and resending verification need the caller, so they sit behind the auth middleware
+	apiRouter.HandleFunc("/auth/logout", authHandler.Logout).Methods("POST")
+	apiRouter.HandleFunc("/auth/verify-email/resend", authHandler.ResendVerification).Methods("POST")
+
+	// Bulk transaction routes have tighter limits of their own on top of the API-wide one
+	sampleLimit := handlers.RateLimit(limits, "generatesample", cfg.RateLimitGenerateSample)
+	importLimit := handlers.RateLimit(limits, "import", cfg.RateLimitImport)
+	exportLimit := handlers.RateLimit(limits, "export", cfg.RateLimitExport)
+
+	// Transactions and accounts belong to an organization, picked by the X-Organization-ID
+	// header under /api or by the path under /api/organizations/{org_id}
+	for _, books := range []*mux.Router{
+		apiRouter.NewRoute().Subrouter(),
+		apiRouter.PathPrefix("/organizations/{org_id}").Subrouter(),
+	} {
+		books.Use(handlers.OrganizationMiddleware(st, st))
 
-	// Auth routes (public)
-	router.HandleFunc("/api/auth/register", authHandler.Register).Methods("POST")
-	router.HandleFunc("/api/auth/login", authHandler.Login).Methods("POST")
+		// Transaction routes (API keys also need the route's scope)
+		read, write := auth.ScopeTransactionsRead, auth.ScopeTransactionsWrite
+		books.HandleFunc("/transactions", handlers.RequireScope(read, transactionHandler.GetTransactions)).Methods("GET")
+		books.HandleFunc("/transactions", handlers.RequireScope(write, transactionHandler.CreateTransaction)).Methods("POST")
+		books.Handle("/transactions/generatesample", sampleLimit(handlers.RequireScope(write, transactionHandler.GenerateSampleTransactions))).Methods("POST")
+		books.Handle("/transactions/import", importLimit(handlers.RequireScope(write, transactionHandler.ImportTransactions))).Methods("POST")
+		books.Handle("/transactions/export", exportLimit(handlers.RequireScope(read, transactionHandler.ExportTransactions))).Methods("GET")
+		books.HandleFunc("/transactions/chain/verify", handlers.RequireScope(read, chainHandler.VerifyChain)).Methods("GET")
+		books.HandleFunc("/transactions/chain/checkpoints", handlers.RequireScope(read, chainHandler.ListCheckpoints)).Methods("GET")
+		books.HandleFunc("/transactions/{id}", handlers.RequireScope(read, transactionHandler.GetTransaction)).Methods("GET")
+		books.HandleFunc("/transactions/{id}", handlers.RequireScope(write, transactionHandler.UpdateTransaction)).Methods("PATCH")
+		books.HandleFunc("/transactions/{id}/void", handlers.RequireScope(write, transactionHandler.VoidTransaction)).Methods("POST")
+		books.HandleFunc("/transactions/{id}/{action:authorize|complete|fail|reverse|cancel}", handlers.RequireScope(write, transactionHandler.AdvanceTransaction)).Methods("POST")
+		books.HandleFunc("/transactions/{id}/history", handlers.RequireScope(read, transactionHandler.GetTransactionHistory)).Methods("GET")
 
-	// Transaction routes (protected by auth middleware)
-	apiRouter.HandleFunc("/transactions", transactionHandler.GetTransactions).Methods("GET")
-	apiRouter.HandleFunc("/transactions", transactionHandler.CreateTransaction).Methods("POST")
-	apiRouter.HandleFunc("/transactions/generatesample", transactionHandler.GenerateSampleTransactions).Methods("POST")
+		// Account routes
+		read, write = auth.ScopeAccountsRead, auth.ScopeAccountsWrite
+		books.HandleFunc("/accounts", handlers.RequireScope(read, accountHandler.ListAccounts)).Methods("GET")
+		books.HandleFunc("/accounts", handlers.RequireScope(write, accountHandler.CreateAccount)).Methods("POST")
+		books.HandleFunc("/accounts/{id}", handlers.RequireScope(read, accountHandler.GetAccount)).Methods("GET")
+		books.HandleFunc("/accounts/{id}/balance", handlers.RequireScope(read, accountHandler.GetBalance)).Methods("GET")
+	}
+
+	// Organization management (token sessions only)
+	apiRouter.HandleFunc("/organizations", organizationHandler.ListOrganizations).Methods("GET")
+	apiRouter.HandleFunc("/organizations", organizationHandler.CreateOrganization).Methods("POST")
+	apiRouter.HandleFunc("/organizations/{id}/members", organizationHandler.ListMembers).Methods("GET")
+	apiRouter.HandleFunc("/organizations/{id}/members/{user_id}", organizationHandler.UpdateMember).Methods("PUT")
+	apiRouter.HandleFunc("/organizations/{id}/members/{user_id}", organizationHandler.RemoveMember).Methods("DELETE")
+	apiRouter.HandleFunc("/organizations/{id}/invitations", organizationHandler.ListInvitations).Methods("GET")
+	apiRouter.HandleFunc("/organizations/{id}/invitations", organizationHandler.CreateInvitation).Methods("POST")
+	apiRouter.HandleFunc("/invitations/accept", organizationHandler.AcceptInvitation).Methods("POST")
+
+	// Two-factor authentication (token sessions only)
+	apiRouter.HandleFunc("/auth/mfa", mfaHandler.Status).Methods("GET")
+	apiRouter.HandleFunc("/auth/mfa/totp", mfaHandler.EnrollTOTP).Methods("POST")
+	apiRouter.HandleFunc("/auth/mfa/totp/confirm", mfaHandler.ConfirmTOTP).Methods("POST")
+	apiRouter.HandleFunc("/auth/mfa/totp/disable", mfaHandler.DisableTOTP).Methods("POST")
+	apiRouter.HandleFunc("/auth/mfa/recovery-codes", mfaHandler.RegenerateRecoveryCodes).Methods("POST")
+
+	// API key management (token sessions only)
+	apiRouter.HandleFunc("/api-keys", apiKeyHandler.ListAPIKeys).Methods("GET")
+	apiRouter.HandleFunc("/api-keys", apiKeyHandler.CreateAPIKey).Methods("POST")
+	apiRouter.HandleFunc("/api-keys/{id}", apiKeyHandler.RevokeAPIKey).Methods("DELETE")
+
+	// Audit trail (token sessions only); staff with audit:read see every user's events
+	apiRouter.HandleFunc("/audit", auditHandler.List).Methods("GET")
+
+	// Admin routes: auditors may read, only admins may change users
+	adminRouter := apiRouter.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(handlers.RequirePermission(auth.PermReadUsers))
+	adminRouter.HandleFunc("/users", adminHandler.ListUsers).Methods("GET")
+	adminRouter.HandleFunc("/users/{id}/organizations", adminHandler.ListUserOrganizations).Methods("GET")
+	adminRouter.Handle("/organizations/{id}/transactions",
+		handlers.RequirePermission(auth.PermReadAnyTransactions)(http.HandlerFunc(transactionHandler.GetOrganizationTransactions))).Methods("GET")
+
+	manage := handlers.RequirePermission(auth.PermManageUsers)
+	adminRouter.Handle("/users/{id}/role", manage(http.HandlerFunc(adminHandler.SetRole))).Methods("PUT")
+	adminRouter.Handle("/users/{id}/disable", manage(http.HandlerFunc(adminHandler.DisableUser))).Methods("POST")
+	adminRouter.Handle("/users/{id}/enable", manage(http.HandlerFunc(adminHandler.EnableUser))).Methods("POST")
+	adminRouter.Handle("/users/{id}/unlock", manage(http.HandlerFunc(adminHandler.UnlockUser))).Methods("POST")
+	adminRouter.Handle("/users/{id}/mfa", manage(http.HandlerFunc(adminHandler.ResetMFA))).Methods("DELETE")
+
+	// Unmatched paths and methods get problem+json errors like every other failure.
+	// Middleware does not run for them, so they take a request ID of their own.
+	// The organization subrouters are left alone: they share prefixes with later
+	// /api routes, which their not-found handlers would shadow.
+	unrouted := handlers.RequestIDMiddleware(handlers.Unrouted(router))
+	for _, r := range []*mux.Router{router, apiRouter, adminRouter} {
+		r.NotFoundHandler = unrouted
+		r.MethodNotAllowedHandler = unrouted
+	}
 
 	// Start server
 	port := ":" + cfg.ServerPort
 	log.Printf("Server starting on port %s", port)
-	log.Printf("JWT Secret: %s", auth.GetJWTSecret())
 	log.Fatal(http.ListenAndServe(port, router))
 }
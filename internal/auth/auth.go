@@ -1,9 +1,11 @@
 package auth
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
+	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -11,33 +13,117 @@ import (
 )
 
 var (
-	jwtSecret []byte
+	keys            *KeySet
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+	denylist        Denylist
+)
+
+// ErrTokenRevoked is returned by ValidateToken for tokens revoked before expiry
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// MFAChallengeTTL is how long the second login step may take
+const MFAChallengeTTL = 5 * time.Minute
+
+// mfaAudience marks MFA challenge tokens; ValidateToken rejects any token with an audience
+const mfaAudience = "mfa"
+
+// Denylist reports whether an access token ID (the jti claim) has been revoked
+type Denylist interface {
+	IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// Scopes an API key can be granted
+const (
+	ScopeTransactionsRead  = "transactions:read"
+	ScopeTransactionsWrite = "transactions:write"
+	ScopeAccountsRead      = "accounts:read"
+	ScopeAccountsWrite     = "accounts:write"
 )
 
 type Claims struct {
 	UserID string `json:"user_id"`
 	Email  string `json:"email"`
+	Role   string `json:"role,omitempty"`
 	jwt.RegisteredClaims
+
+	// APIKeyID and Scopes are set when the request authenticated with an API key
+	// instead of a token; they are never part of a JWT
+	APIKeyID string   `json:"-"`
+	Scopes   []string `json:"-"`
+}
+
+// HasScope reports whether the caller may act within scope. Token sessions act
+// with the user's full rights; API keys are limited to the scopes they were granted.
+func (c *Claims) HasScope(scope string) bool {
+	if c.APIKeyID == "" {
+		return true
+	}
+	return slices.Contains(c.Scopes, scope)
+}
+
+// Init initializes the auth package with the signing keys and token lifetimes
+func Init(cfg *config.Config) error {
+	if cfg.AccessTokenTTL > 0 {
+		accessTokenTTL = cfg.AccessTokenTTL
+	}
+	if cfg.RefreshTokenTTL > 0 {
+		refreshTokenTTL = cfg.RefreshTokenTTL
+	}
+
+	alg := cfg.JWTSigningAlg
+	if alg == "" {
+		alg = AlgEdDSA
+	}
+	// Retired keys must verify every token they signed until it expires
+	grace := cfg.JWTKeyGracePeriod
+	if grace < accessTokenTTL {
+		grace = accessTokenTTL
+	}
+
+	ks, err := NewKeySet(cfg.JWTKeysDir, alg, grace)
+	if err != nil {
+		return err
+	}
+	keys = ks
+	return nil
 }
 
-// Init initializes the auth package with the JWT secret
-func Init(cfg *config.Config) {
-	jwtSecret = []byte(cfg.JWTSecret)
+// Keys returns the key set tokens are signed and verified with
+func Keys() *KeySet {
+	return keys
 }
 
-// GetJWTSecret returns the JWT secret key (for debugging only)
-func GetJWTSecret() string {
-	return string(jwtSecret)
+// SetDenylist makes ValidateToken reject access tokens revoked in d
+func SetDenylist(d Denylist) {
+	denylist = d
 }
 
-// GenerateJWT creates a new JWT token for a user
-func GenerateJWT(userID, email string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+// AccessTokenTTL returns how long issued access tokens are valid
+func AccessTokenTTL() time.Duration {
+	return accessTokenTTL
+}
+
+// RefreshTokenTTL returns how long issued refresh tokens are valid
+func RefreshTokenTTL() time.Duration {
+	return refreshTokenTTL
+}
+
+// GenerateJWT creates a short-lived access token for a user with a unique jti
+func GenerateJWT(userID, email, role string) (string, error) {
+	expirationTime := time.Now().Add(accessTokenTTL)
+
+	jti, err := GenerateRandomString(16)
+	if err != nil {
+		return "", err
+	}
 
 	claims := &Claims{
 		UserID: userID,
 		Email:  email,
+		Role:   role,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
@@ -45,20 +131,75 @@ func GenerateJWT(userID, email string) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	key := keys.Active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// GenerateMFAChallenge creates the token returned by the first login step for a
+// user with two-factor authentication. It only proves the password was checked
+// and cannot be used as an access token.
+func GenerateMFAChallenge(userID string) (string, error) {
+	jti, err := GenerateRandomString(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Audience:  jwt.ClaimStrings{mfaAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(MFAChallengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    "transaction-logger",
+		},
+	}
+
+	key := keys.Active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// ValidateMFAChallenge validates a token from GenerateMFAChallenge. Challenges are
+// checked against the denylist too, so revoking one after use makes it single-use.
+func ValidateMFAChallenge(tokenString string) (*Claims, error) {
+	return parseToken(tokenString, jwt.WithAudience(mfaAudience))
 }
 
 // ValidateToken validates the JWT token
 func ValidateToken(tokenString string) (*Claims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	// Tokens minted for another purpose, such as MFA challenges, carry an audience
+	if len(claims.Audience) > 0 {
+		return nil, errors.New("not an access token")
+	}
+	return claims, nil
+}
+
+// parseToken verifies a token's signature, times and revocation
+func parseToken(tokenString string, opts ...jwt.ParserOption) (*Claims, error) {
 	claims := &Claims{}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keys.Lookup(kid)
+		if !ok {
+			return nil, errors.New("unknown signing key")
+		}
+		// The key, not the token, decides the algorithm
+		if token.Method.Alg() != key.Method.Alg() {
 			return nil, errors.New("unexpected signing method")
 		}
-		return jwtSecret, nil
-	})
+		return key.Private.Public(), nil
+	}, opts...)
 
 	if err != nil {
 		return nil, err
@@ -68,6 +209,16 @@ func ValidateToken(tokenString string) (*Claims, error) {
 		return nil, errors.New("invalid token")
 	}
 
+	if denylist != nil && claims.ID != "" {
+		revoked, err := denylist.IsAccessTokenRevoked(context.Background(), claims.ID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+
 	return claims, nil
 }
 
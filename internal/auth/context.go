@@ -0,0 +1,47 @@
+package auth
+
+import "context"
+
+// contextKey is unexported so only this package can set or read the claims
+type contextKey struct{}
+
+// WithClaims returns a copy of ctx carrying the authenticated user's claims
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, contextKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims stored by WithClaims, if any
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(contextKey{}).(*Claims)
+	return claims, ok && claims != nil
+}
+
+// UserFromContext returns the authenticated user's ID, or false if the request
+// was not authenticated
+func UserFromContext(ctx context.Context) (string, bool) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok || claims.UserID == "" {
+		return "", false
+	}
+	return claims.UserID, true
+}
+
+// orgContextKey stores the organization a request acts in
+type orgContextKey struct{}
+
+// Organization is the organization a request acts in and the caller's role there
+type Organization struct {
+	ID   string
+	Role string
+}
+
+// WithOrganization returns a copy of ctx acting in org
+func WithOrganization(ctx context.Context, org Organization) context.Context {
+	return context.WithValue(ctx, orgContextKey{}, org)
+}
+
+// OrganizationFromContext returns the organization stored by WithOrganization, if any
+func OrganizationFromContext(ctx context.Context) (Organization, bool) {
+	org, ok := ctx.Value(orgContextKey{}).(Organization)
+	return org, ok && org.ID != ""
+}
@@ -0,0 +1,361 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// rsaKeyBits is the size of generated RSA keys and the minimum accepted from files
+const rsaKeyBits = 2048
+
+// lookupReloadInterval is the least time between reloads prompted by unknown kids,
+// so tokens with made-up kids cannot have every request read the key directory
+const lookupReloadInterval = 5 * time.Second
+
+// SigningKey is a private key used to sign tokens, identified by its kid
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	Private   crypto.Signer
+	CreatedAt time.Time
+}
+
+func newSigningKey(id string, private crypto.PrivateKey, createdAt time.Time) (*SigningKey, error) {
+	key := &SigningKey{ID: id, CreatedAt: createdAt}
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < rsaKeyBits {
+			return nil, fmt.Errorf("RSA key %s is %d bits; at least %d are required", id, k.N.BitLen(), rsaKeyBits)
+		}
+		key.Method, key.Private = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.Private = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("key %s has unsupported type %T", id, private)
+	}
+	return key, nil
+}
+
+// GenerateKey creates a signing key for alg (RS256 or EdDSA) with a random kid
+func GenerateKey(alg string) (*SigningKey, error) {
+	var private crypto.PrivateKey
+	var err error
+	switch alg {
+	case AlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	// Kids sort by creation date, which keeps key directories readable
+	now := time.Now()
+	id := now.UTC().Format("20060102T150405") + "-" + hex.EncodeToString(suffix)
+	return newSigningKey(id, private, now)
+}
+
+// LoadKeys reads every *.pem private key in dir. A key's kid is its file name
+// without the extension and its creation time is the file's modification time.
+func LoadKeys(dir string) ([]*SigningKey, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*SigningKey, 0, len(paths))
+	for _, path := range paths {
+		key, err := loadKey(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func loadKey(path string) (*SigningKey, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+
+	var private crypto.PrivateKey
+	switch block.Type {
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	id := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return newSigningKey(id, private, info.ModTime())
+}
+
+// WriteKey saves key to dir as <kid>.pem in PKCS #8 form, readable only by the owner
+func WriteKey(dir string, key *SigningKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, key.ID+".pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return err
+	}
+	return os.Chtimes(path, key.CreatedAt, key.CreatedAt)
+}
+
+// KeySet holds the active signing key and the retired keys still accepted for
+// verification. A key is retired when a newer one is created and is dropped once
+// it has been retired for longer than the grace period.
+type KeySet struct {
+	mu          sync.RWMutex
+	keys        []*SigningKey // newest first
+	alg         string
+	gracePeriod time.Duration
+	// dir, if set, is where keys are loaded from and rotated keys are written
+	dir string
+	// lastLookupReload is when Lookup last reloaded the directory
+	lastLookupReload time.Time
+}
+
+// NewKeySet loads the keys in dir, generating a first alg key if there are none.
+// With an empty dir keys live only in memory, so tokens do not survive a restart.
+func NewKeySet(dir, alg string, gracePeriod time.Duration) (*KeySet, error) {
+	ks := &KeySet{alg: alg, gracePeriod: gracePeriod, dir: dir}
+	if dir != "" {
+		if err := ks.Reload(); err != nil {
+			return nil, err
+		}
+	}
+	if len(ks.keys) == 0 {
+		if _, err := ks.Rotate(time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	return ks, nil
+}
+
+// Reload replaces the keys with those in the directory, picking up keys rotated by
+// other instances. If the directory holds no keys the current ones are kept, so
+// there is always an active key.
+func (ks *KeySet) Reload() error {
+	keys, err := LoadKeys(ks.dir)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if len(keys) == 0 {
+		return nil
+	}
+	ks.keys = keys
+	ks.sortAndPrune(time.Now())
+	return nil
+}
+
+// Active returns the key new tokens are signed with
+func (ks *KeySet) Active() *SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.keys[0]
+}
+
+// Lookup returns the key with the kid if it is active or within its grace period.
+// An unknown kid may belong to a key another instance has just rotated in, so the
+// directory is reloaded and searched again, at most once per lookupReloadInterval.
+func (ks *KeySet) Lookup(kid string) (*SigningKey, bool) {
+	if key, ok := ks.find(kid); ok || !ks.claimLookupReload(time.Now()) {
+		return key, ok
+	}
+	if err := ks.Reload(); err != nil {
+		log.Printf("Failed to reload signing keys: %v", err)
+		return nil, false
+	}
+	return ks.find(kid)
+}
+
+func (ks *KeySet) find(kid string) (*SigningKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	for _, key := range ks.keys {
+		if key.ID == kid {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// claimLookupReload reports whether Lookup may reload the directory now, and if so
+// starts a new interval so concurrent lookups do not reload as well
+func (ks *KeySet) claimLookupReload(now time.Time) bool {
+	if ks.dir == "" {
+		return false
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if now.Sub(ks.lastLookupReload) < lookupReloadInterval {
+		return false
+	}
+	ks.lastLookupReload = now
+	return true
+}
+
+// Rotate makes a freshly generated key active and drops keys whose grace period has ended
+func (ks *KeySet) Rotate(now time.Time) (*SigningKey, error) {
+	key, err := GenerateKey(ks.alg)
+	if err != nil {
+		return nil, err
+	}
+	key.CreatedAt = now
+
+	if ks.dir != "" {
+		if err := WriteKey(ks.dir, key); err != nil {
+			return nil, err
+		}
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys = append(ks.keys, key)
+	ks.sortAndPrune(now)
+	return key, nil
+}
+
+// RotateIfDue rotates when the active key is older than maxAge. Keys rotated by
+// other instances sharing the directory are loaded first, so only one rotates.
+func (ks *KeySet) RotateIfDue(maxAge time.Duration, now time.Time) (bool, error) {
+	if ks.dir != "" {
+		if err := ks.Reload(); err != nil {
+			return false, err
+		}
+	}
+
+	ks.mu.RLock()
+	due := len(ks.keys) == 0 || now.Sub(ks.keys[0].CreatedAt) >= maxAge
+	ks.mu.RUnlock()
+	if !due {
+		return false, nil
+	}
+
+	_, err := ks.Rotate(now)
+	return err == nil, err
+}
+
+// sortAndPrune orders keys newest first and drops those retired for longer than
+// the grace period, deleting their files. Callers must hold the write lock.
+func (ks *KeySet) sortAndPrune(now time.Time) {
+	sort.Slice(ks.keys, func(i, j int) bool {
+		return ks.keys[i].CreatedAt.After(ks.keys[j].CreatedAt)
+	})
+
+	for i := 1; i < len(ks.keys); i++ {
+		// A key was retired when the next newer key was created
+		if now.Sub(ks.keys[i-1].CreatedAt) <= ks.gracePeriod {
+			continue
+		}
+		if ks.dir != "" {
+			// Best effort: another instance may already have removed the file,
+			// and a leftover file is pruned again on the next reload
+			for _, expired := range ks.keys[i:] {
+				os.Remove(filepath.Join(ks.dir, expired.ID+".pem"))
+			}
+		}
+		ks.keys = ks.keys[:i]
+		break
+	}
+}
+
+// JSONWebKey is the public half of a signing key in RFC 7517 form
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 keys
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JSONWebKeySet is the document served at /.well-known/jwks.json
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the public keys of every key that can still verify tokens
+func (ks *KeySet) JWKS() JSONWebKeySet {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	set := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(ks.keys))}
+	for _, key := range ks.keys {
+		jwk := JSONWebKey{KeyID: key.ID, Use: "sig", Algorithm: key.Method.Alg()}
+		switch pub := key.Private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
@@ -0,0 +1,37 @@
+package auth
+
+import "slices"
+
+// Permissions granted by roles on top of access to the user's own data
+const (
+	// PermReadUsers allows listing users
+	PermReadUsers = "users:read"
+	// PermManageUsers allows changing roles and disabling users
+	PermManageUsers = "users:manage"
+	// PermReadAnyTransactions allows viewing any user's transactions
+	PermReadAnyTransactions = "transactions:read:any"
+	// PermReadAudit allows viewing every user's audit events
+	PermReadAudit = "audit:read"
+)
+
+// rolePermissions lists what each role may do. Roles are named by the
+// constants in the models package; unknown roles have no permissions.
+var rolePermissions = map[string][]string{
+	"user":    nil,
+	"auditor": {PermReadUsers, PermReadAnyTransactions, PermReadAudit},
+	"admin":   {PermReadUsers, PermReadAnyTransactions, PermReadAudit, PermManageUsers},
+}
+
+// RoleHas reports whether role grants permission
+func RoleHas(role, permission string) bool {
+	return slices.Contains(rolePermissions[role], permission)
+}
+
+// Can reports whether the caller holds permission. API keys never carry a
+// role's permissions, only their scopes.
+func (c *Claims) Can(permission string) bool {
+	if c.APIKeyID != "" {
+		return false
+	}
+	return RoleHas(c.Role, permission)
+}
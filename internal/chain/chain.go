@@ -0,0 +1,193 @@
+// Package chain verifies the hash chain that links each organization's
+// transactions and their status changes, and keeps signed checkpoints of it. Links
+// themselves are written by the store as transactions are inserted and updated;
+// see models.Transaction.ChainHash and models.StatusChange.ChainHash.
+package chain
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+)
+
+// pageSize is how many links Verify reads at a time
+const pageSize = 500
+
+// Verify walks the organization's chain from the first link and reports the first
+// one that is missing, out of order or does not match its hash. Checkpoints signed
+// by signer must match the links they cover and the chain must reach the latest one,
+// so rewriting or truncating history behind a checkpoint is caught even if every
+// hash after it was recomputed. Each transaction's status must follow its chained
+// status changes, so it cannot be edited without leaving a link behind.
+func Verify(ctx context.Context, chains store.ChainStore, signer *Signer, orgID string) (*models.ChainReport, error) {
+	report := &models.ChainReport{OrganizationID: orgID, VerifiedAt: time.Now().UTC()}
+
+	checkpoints, err := chains.ListChainCheckpoints(ctx, orgID, 0)
+	if err != nil {
+		return nil, err
+	}
+	// Only checkpoints with a valid signature are trusted
+	trusted := map[int64]models.ChainCheckpoint{}
+	var latest int64
+	for _, cp := range checkpoints {
+		switch err := signer.Verify(&cp); err {
+		case nil:
+			trusted[cp.Seq] = cp
+			latest = max(latest, cp.Seq)
+			report.Checkpoints++
+		case ErrUnknownKey:
+			report.UnverifiedCheckpoints++
+		default:
+			report.Broken = &models.ChainBreak{Seq: cp.Seq, Reason: fmt.Sprintf("checkpoint %s: %v", cp.ID, err)}
+			return report, nil
+		}
+	}
+
+	var seq int64
+	var prevHash string
+	statuses := statusTrails{}
+	for {
+		links, err := chains.ListChainLinks(ctx, orgID, seq, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, link := range links {
+			if brk := checkLink(link, seq, prevHash, trusted); brk != nil {
+				report.Broken = brk
+				return report, nil
+			}
+			if brk := statuses.follow(link); brk != nil {
+				report.Broken = brk
+				return report, nil
+			}
+			seq = link.Seq()
+			_, prevHash = link.Hashes()
+			report.Links++
+		}
+		if len(links) < pageSize {
+			break
+		}
+	}
+	report.HeadHash = prevHash
+
+	head, err := chains.GetChainHead(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case seq < latest:
+		report.Broken = &models.ChainBreak{Seq: seq + 1, Reason: fmt.Sprintf("chain ends before the checkpoint at seq %d", latest)}
+	case seq < head.Seq:
+		report.Broken = &models.ChainBreak{Seq: seq + 1, Reason: fmt.Sprintf("chain ends before its head at seq %d", head.Seq)}
+	case seq != head.Seq || prevHash != head.Hash:
+		report.Broken = &models.ChainBreak{Seq: seq, Reason: "last link does not match the chain head"}
+	default:
+		// Statuses are only compared once the chain itself is known to be whole
+		report.Broken = statuses.check()
+		report.Valid = report.Broken == nil
+	}
+	return report, nil
+}
+
+// checkLink returns the problem with link if it does not follow the link at seq with prevHash
+func checkLink(link models.ChainLink, seq int64, prevHash string, trusted map[int64]models.ChainCheckpoint) *models.ChainBreak {
+	linkPrev, hash := link.Hashes()
+	switch {
+	case link.Seq() != seq+1:
+		return &models.ChainBreak{Seq: seq + 1, Reason: "link is missing"}
+	case linkPrev != prevHash:
+		return &models.ChainBreak{Seq: link.Seq(), TransactionID: link.TransactionID(), Reason: "previous hash does not match the previous link"}
+	case link.ContentHash(prevHash) != hash:
+		return &models.ChainBreak{Seq: link.Seq(), TransactionID: link.TransactionID(), Reason: "content does not match its hash"}
+	}
+	if cp, ok := trusted[link.Seq()]; ok && cp.Hash != hash {
+		return &models.ChainBreak{Seq: link.Seq(), TransactionID: link.TransactionID(), Reason: fmt.Sprintf("hash differs from checkpoint %s", cp.ID)}
+	}
+	return nil
+}
+
+// statusTrail follows one transaction's status along the chain
+type statusTrail struct {
+	current string // the status the transaction has now
+	last    string // the status its last chained change moved it to
+	seq     int64  // the position of that change, or of the transaction until it has one
+	changed bool   // whether a change has been seen
+}
+
+// statusTrails holds the trail of every transaction seen so far, keyed by ID
+type statusTrails map[string]*statusTrail
+
+// follow records link, returning the problem if it is a status change that does not
+// continue from its transaction's previous one. The first change records the status
+// the transaction was created with and has no From.
+func (trails statusTrails) follow(link models.ChainLink) *models.ChainBreak {
+	if link.StatusChange == nil {
+		trails[link.Transaction.ID] = &statusTrail{current: link.Transaction.Status, seq: link.Transaction.ChainSeq}
+		return nil
+	}
+
+	c := link.StatusChange
+	trail, ok := trails[c.TransactionID]
+	switch {
+	case !ok:
+		return &models.ChainBreak{Seq: c.ChainSeq, TransactionID: c.TransactionID, Reason: "status change precedes its transaction"}
+	case !trail.changed && c.From != "":
+		return &models.ChainBreak{Seq: c.ChainSeq, TransactionID: c.TransactionID, Reason: "initial status is missing"}
+	case trail.changed && c.From != trail.last:
+		return &models.ChainBreak{Seq: c.ChainSeq, TransactionID: c.TransactionID, Reason: fmt.Sprintf("status change from %s does not follow the change to %s", c.From, trail.last)}
+	}
+	trail.last, trail.seq, trail.changed = c.To, c.ChainSeq, true
+	return nil
+}
+
+// check returns the earliest transaction whose current status is not the one its
+// chained changes end at
+func (trails statusTrails) check() *models.ChainBreak {
+	var brk *models.ChainBreak
+	for id, trail := range trails {
+		if trail.changed && trail.current == trail.last || brk != nil && brk.Seq < trail.seq {
+			continue
+		}
+		reason := "initial status is missing"
+		if trail.changed {
+			reason = fmt.Sprintf("status is %s but its last chained change is to %s", trail.current, trail.last)
+		}
+		brk = &models.ChainBreak{Seq: trail.seq, TransactionID: id, Reason: reason}
+	}
+	return brk
+}
+
+// Checkpoint signs and stores a checkpoint of every chain that has grown since its
+// last one, returning how many were created. Instances racing to checkpoint the
+// same head create it once.
+func Checkpoint(ctx context.Context, chains store.ChainStore, signer *Signer) (int, error) {
+	heads, err := chains.ListChainHeads(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, head := range heads {
+		last, err := chains.ListChainCheckpoints(ctx, head.OrganizationID, 1)
+		if err != nil {
+			return created, err
+		}
+		if len(last) > 0 && last[0].Seq >= head.Seq {
+			continue
+		}
+
+		cp := models.NewChainCheckpoint(head)
+		signer.Sign(cp)
+		switch err := chains.CreateChainCheckpoint(ctx, cp); err {
+		case nil:
+			created++
+		case store.ErrAlreadyExists:
+		default:
+			return created, err
+		}
+	}
+	return created, nil
+}
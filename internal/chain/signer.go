@@ -0,0 +1,119 @@
+package chain
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"transaction-logger/internal/models"
+)
+
+// ErrUnknownKey is returned when a checkpoint was signed with a different key
+var ErrUnknownKey = errors.New("checkpoint signed with an unknown key")
+
+// ErrBadSignature is returned when a checkpoint's signature does not match its contents
+var ErrBadSignature = errors.New("checkpoint signature is invalid")
+
+// Signer signs checkpoints with an Ed25519 key kept apart from the token signing
+// keys, which are rotated away and would leave old checkpoints unverifiable
+type Signer struct {
+	key ed25519.PrivateKey
+	id  string
+}
+
+// NewSigner returns a Signer for key, identified by a fingerprint of its public half
+func NewSigner(key ed25519.PrivateKey) *Signer {
+	sum := sha256.Sum256(key.Public().(ed25519.PublicKey))
+	return &Signer{key: key, id: hex.EncodeToString(sum[:8])}
+}
+
+// LoadSigner reads the PKCS #8 Ed25519 key at path, generating and saving one if
+// the file does not exist. An empty path generates a key that lives only in memory.
+func LoadSigner(path string) (*Signer, error) {
+	if path == "" {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigner(key), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return generateSigner(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s: no PKCS #8 private key found", path)
+	}
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	key, ok := private.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: checkpoint keys must be Ed25519, not %T", path, private)
+	}
+	return NewSigner(key), nil
+}
+
+func generateSigner(path string) (*Signer, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	// O_EXCL so an instance starting at the same time cannot overwrite our key
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return NewSigner(key), f.Close()
+}
+
+// KeyID identifies the signer's key in the checkpoints it signs
+func (s *Signer) KeyID() string {
+	return s.id
+}
+
+// PublicKey returns the key that verifies the signer's checkpoints
+func (s *Signer) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// Sign sets the checkpoint's key ID and signature
+func (s *Signer) Sign(cp *models.ChainCheckpoint) {
+	cp.KeyID = s.id
+	cp.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, cp.Payload()))
+}
+
+// Verify checks the checkpoint's signature, returning ErrUnknownKey or ErrBadSignature
+func (s *Signer) Verify(cp *models.ChainCheckpoint) error {
+	if cp.KeyID != s.id {
+		return ErrUnknownKey
+	}
+	sig, err := base64.StdEncoding.DecodeString(cp.Signature)
+	if err != nil || !ed25519.Verify(s.PublicKey(), cp.Payload(), sig) {
+		return ErrBadSignature
+	}
+	return nil
+}
@@ -1,6 +1,13 @@
 package config
 
-import "os"
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"transaction-logger/internal/ratelimit"
+)
 
 type Config struct {
 	DBHost     string
@@ -9,7 +16,68 @@ type Config struct {
 	DBPassword string
 	DBName     string
 	ServerPort string
-	JWTSecret  string
+
+	// JWTKeysDir holds the PEM private keys tokens are signed with; empty keeps keys in memory
+	JWTKeysDir string
+	// JWTSigningAlg is the algorithm of generated keys: "EdDSA" or "RS256"
+	JWTSigningAlg string
+	// JWTKeyRotation is how old the active signing key may get before it is replaced
+	JWTKeyRotation time.Duration
+	// JWTKeyGracePeriod is how long a replaced key still verifies tokens
+	JWTKeyGracePeriod time.Duration
+
+	// StorageDriver selects the store backend: "postgres" or "memory"
+	StorageDriver string
+
+	// AccessTokenTTL is how long an access token is valid
+	AccessTokenTTL time.Duration
+	// RefreshTokenTTL is how long a refresh token can be exchanged for a new access token
+	RefreshTokenTTL time.Duration
+
+	// IdempotencyKeyTTL is how long Idempotency-Key responses are kept for replay
+	IdempotencyKeyTTL time.Duration
+
+	// MailDriver selects how emails are sent: "smtp", or "log" to write them to MailDir or the log
+	MailDriver string
+	// MailFrom is the From address of every email
+	MailFrom string
+	// MailDir is where the log driver writes .eml files; empty logs them instead
+	MailDir      string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	// AppBaseURL is the web app that links in emails point to
+	AppBaseURL string
+
+	// LoginLockoutThreshold is how many failed logins in a row lock an email address; 0 disables it
+	LoginLockoutThreshold int
+	// LoginIPLockoutThreshold is the same for a client IP, which may be shared by many users
+	LoginIPLockoutThreshold int
+	// LoginLockoutDuration is how long a lockout lasts
+	LoginLockoutDuration time.Duration
+	// TrustProxyHeaders takes the client IP from X-Forwarded-For; only enable it behind a proxy
+	TrustProxyHeaders bool
+
+	// ChainSigningKeyFile is the Ed25519 PEM key hash chain checkpoints are signed with,
+	// generated if missing; empty keeps a key in memory
+	ChainSigningKeyFile string
+	// ChainCheckpointInterval is how often chain heads are checkpointed; 0 disables it
+	ChainCheckpointInterval time.Duration
+
+	// RateLimitStore keeps rate limit buckets: "memory" per instance, or "postgres" to
+	// share them between instances
+	RateLimitStore string
+	// RateLimitAPI applies to each caller across every /api route
+	RateLimitAPI ratelimit.Limit
+	// RateLimitAuth applies to each client IP across the public auth routes
+	RateLimitAuth ratelimit.Limit
+	// RateLimitGenerateSample applies to each caller generating sample transactions
+	RateLimitGenerateSample ratelimit.Limit
+	// RateLimitImport applies to each caller importing transactions
+	RateLimitImport ratelimit.Limit
+	// RateLimitExport applies to each caller exporting transactions
+	RateLimitExport ratelimit.Limit
 }
 
 func LoadConfig() *Config {
@@ -20,7 +88,42 @@ func LoadConfig() *Config {
 		DBPassword: getEnv("POSTGRES_PASSWORD", "postgres"),
 		DBName:     getEnv("POSTGRES_DB", "transaction_logger"),
 		ServerPort: getEnv("PORT", "8080"),
-		JWTSecret:  getEnv("JWT_SECRET", "default-jwt-secret-change-in-production"),
+
+		JWTKeysDir:        getEnv("JWT_KEYS_DIR", ""),
+		JWTSigningAlg:     getEnv("JWT_SIGNING_ALG", "EdDSA"),
+		JWTKeyRotation:    getEnvDuration("JWT_KEY_ROTATION", 30*24*time.Hour),
+		JWTKeyGracePeriod: getEnvDuration("JWT_KEY_GRACE_PERIOD", 24*time.Hour),
+
+		StorageDriver: getEnv("STORAGE_DRIVER", "postgres"),
+
+		AccessTokenTTL:  getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+		IdempotencyKeyTTL: getEnvDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
+
+		MailDriver:   getEnv("MAIL_DRIVER", "log"),
+		MailFrom:     getEnv("MAIL_FROM", "Transaction Logger <no-reply@localhost>"),
+		MailDir:      getEnv("MAIL_DIR", ""),
+		SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		AppBaseURL:   getEnv("APP_BASE_URL", "http://localhost:8080"),
+
+		LoginLockoutThreshold:   getEnvInt("LOGIN_LOCKOUT_THRESHOLD", 10),
+		LoginIPLockoutThreshold: getEnvInt("LOGIN_IP_LOCKOUT_THRESHOLD", 50),
+		LoginLockoutDuration:    getEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		TrustProxyHeaders:       getEnvBool("TRUST_PROXY_HEADERS", false),
+
+		ChainSigningKeyFile:     getEnv("CHAIN_SIGNING_KEY_FILE", ""),
+		ChainCheckpointInterval: getEnvDuration("CHAIN_CHECKPOINT_INTERVAL", time.Hour),
+
+		RateLimitStore:          getEnv("RATE_LIMIT_STORE", "memory"),
+		RateLimitAPI:            getEnvRateLimit("RATE_LIMIT_API", ratelimit.Limit{Requests: 600, Per: time.Minute}),
+		RateLimitAuth:           getEnvRateLimit("RATE_LIMIT_AUTH", ratelimit.Limit{Requests: 30, Per: time.Minute}),
+		RateLimitGenerateSample: getEnvRateLimit("RATE_LIMIT_GENERATE_SAMPLE", ratelimit.Limit{Requests: 5, Per: time.Minute}),
+		RateLimitImport:         getEnvRateLimit("RATE_LIMIT_IMPORT", ratelimit.Limit{Requests: 10, Per: time.Minute}),
+		RateLimitExport:         getEnvRateLimit("RATE_LIMIT_EXPORT", ratelimit.Limit{Requests: 10, Per: time.Minute}),
 	}
 }
 
@@ -30,3 +133,55 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration for %s: %q, using %v", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid integer for %s: %q, using %v", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid boolean for %s: %q, using %v", key, value, fallback)
+		return fallback
+	}
+	return b
+}
+
+func getEnvRateLimit(key string, fallback ratelimit.Limit) ratelimit.Limit {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	limit, err := ratelimit.Parse(value)
+	if err != nil {
+		log.Printf("Invalid rate limit for %s: %q, using %v", key, value, fallback)
+		return fallback
+	}
+	return limit
+}
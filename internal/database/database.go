@@ -50,45 +50,3 @@ func NewDB(cfg *config.Config) (*Database, error) {
 func (d *Database) Close() error {
 	return d.DB.Close()
 }
-
-// InitSchema initializes the database schema
-func (db *Database) InitSchema() error {
-	// Create users table
-	_, err := db.DB.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			id TEXT PRIMARY KEY,
-			email TEXT NOT NULL UNIQUE,
-			password TEXT NOT NULL,
-			created_at TIMESTAMP NOT NULL,
-			updated_at TIMESTAMP NOT NULL
-		);
-	`)
-
-	if err != nil {
-		return err
-	}
-
-	// Create transactions table with foreign key to users
-	_, err = db.DB.Exec(`
-		CREATE TABLE IF NOT EXISTS transactions (
-			id TEXT PRIMARY KEY,
-			timestamp TIMESTAMP NOT NULL,
-			sender_account TEXT NOT NULL,
-			receiver_account TEXT NOT NULL,
-			amount DECIMAL(15, 2) NOT NULL,
-			currency TEXT NOT NULL,
-			transaction_type TEXT NOT NULL,
-			status TEXT NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			user_id TEXT NOT NULL,
-			CONSTRAINT fk_user
-				FOREIGN KEY (user_id)
-				REFERENCES users(id)
-				ON DELETE CASCADE
-		);
-
-		CREATE INDEX IF NOT EXISTS idx_transactions_user_id ON transactions(user_id);
-	`)
-
-	return err
-}
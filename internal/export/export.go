@@ -0,0 +1,122 @@
+// Package export writes transactions to downloadable file formats one row at a
+// time, so exports of any size are produced in constant memory.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"transaction-logger/internal/models"
+)
+
+// Supported export formats
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+	FormatXLSX   = "xlsx"
+)
+
+// contentTypes maps each format to its MIME type
+var contentTypes = map[string]string{
+	FormatCSV:    "text/csv",
+	FormatNDJSON: "application/x-ndjson",
+	FormatXLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// header is the column order used by the tabular formats
+var header = []string{
+	"id", "timestamp", "sender_account", "receiver_account",
+	"amount", "currency", "transaction_type", "status",
+}
+
+// Writer streams transactions in a file format
+type Writer interface {
+	// Write appends a single transaction
+	Write(t *models.Transaction) error
+	// Close flushes any buffered data and finishes the file
+	Close() error
+}
+
+// ContentType returns the MIME type for the format, or "" if it is unsupported
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// FormatForMediaType returns the format producing the given MIME type, or "" if none does
+func FormatForMediaType(mediaType string) string {
+	for format, ct := range contentTypes {
+		if ct == mediaType {
+			return format
+		}
+	}
+	return ""
+}
+
+// NewWriter returns a Writer for the format that writes to w
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatNDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	}
+	return nil, fmt.Errorf("unsupported export format: %s", format)
+}
+
+func record(t *models.Transaction) []string {
+	return []string{
+		t.ID,
+		t.Timestamp.UTC().Format(time.RFC3339),
+		escapeFormula(t.SenderAccount),
+		escapeFormula(t.ReceiverAccount),
+		t.Amount.String(),
+		t.Currency,
+		t.TransactionType,
+		t.Status,
+	}
+}
+
+// escapeFormula prefixes free text that a spreadsheet would read as a formula
+// with a single quote, so opening an export cannot run anything
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w)}
+	return cw, cw.w.Write(header)
+}
+
+func (c *csvWriter) Write(t *models.Transaction) error {
+	return c.w.Write(record(t))
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(t *models.Transaction) error {
+	return n.enc.Encode(t)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+)
+
+type AccountHandler struct {
+	accounts store.AccountStore
+	auditor  *Auditor
+}
+
+func NewAccountHandler(accounts store.AccountStore, auditor *Auditor) *AccountHandler {
+	return &AccountHandler{accounts: accounts, auditor: auditor}
+}
+
+// CreateAccount creates a ledger account in the active organization
+func (h *AccountHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context (set by AuthMiddleware)
+	userID, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	// Get the active organization (set by OrganizationMiddleware)
+	orgID, ok := requireOrganization(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.CreateAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	if fields := validateRequest(req); len(fields) > 0 {
+		writeError(w, r, validationError(fields))
+		return
+	}
+
+	account := models.NewAccount(orgID, userID, req.Name, req.Currency)
+	if err := h.accounts.CreateAccount(r.Context(), account); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.auditor.record(r, models.NewAuditEvent(models.AuditAccountCreate, models.AuditTargetAccount, account.ID).WithDiff(nil, account))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(account)
+}
+
+// ListAccounts returns the active organization's accounts
+func (h *AccountHandler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	// Get the active organization (set by OrganizationMiddleware)
+	orgID, ok := requireOrganization(w, r)
+	if !ok {
+		return
+	}
+
+	accounts, err := h.accounts.ListAccounts(r.Context(), orgID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": accounts,
+	})
+}
+
+// GetAccount returns a single account in the active organization
+func (h *AccountHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
+	// Get the active organization (set by OrganizationMiddleware)
+	orgID, ok := requireOrganization(w, r)
+	if !ok {
+		return
+	}
+
+	account, err := h.accounts.GetAccount(r.Context(), orgID, mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(account)
+}
+
+// GetBalance returns an account's balance, either now or as of the as_of query parameter (RFC 3339)
+func (h *AccountHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
+	// Get the active organization (set by OrganizationMiddleware)
+	orgID, ok := requireOrganization(w, r)
+	if !ok {
+		return
+	}
+
+	asOf := time.Now()
+	if value := r.URL.Query().Get("as_of"); value != "" {
+		var err error
+		if asOf, err = time.Parse(time.RFC3339, value); err != nil {
+			writeError(w, r, badRequest("invalid as_of: expected RFC 3339 timestamp"))
+			return
+		}
+	}
+
+	account, err := h.accounts.GetAccount(r.Context(), orgID, mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	balance, err := h.accounts.AccountBalance(r.Context(), account, asOf)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(balance)
+}
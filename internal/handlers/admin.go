@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"transaction-logger/internal/auth"
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+)
+
+// AdminHandler serves the staff-only /api/admin endpoints. Routes are guarded
+// with RequirePermission; the handlers themselves do not check roles.
+type AdminHandler struct {
+	users   store.UserStore
+	tokens  store.TokenStore
+	orgs    store.OrganizationStore
+	mfa     store.MFAStore
+	limiter *LoginLimiter
+	auditor *Auditor
+}
+
+func NewAdminHandler(users store.UserStore, tokens store.TokenStore, orgs store.OrganizationStore, mfa store.MFAStore, limiter *LoginLimiter, auditor *Auditor) *AdminHandler {
+	return &AdminHandler{users: users, tokens: tokens, orgs: orgs, mfa: mfa, limiter: limiter, auditor: auditor}
+}
+
+// ListUsersResponse represents the paginated response for users
+type ListUsersResponse struct {
+	Data       []models.User `json:"data"`
+	Pagination struct {
+		Total       int  `json:"total"`
+		Count       int  `json:"count"`
+		PerPage     int  `json:"per_page"`
+		CurrentPage int  `json:"current_page"`
+		TotalPages  int  `json:"total_pages"`
+		HasMore     bool `json:"has_more"`
+	} `json:"pagination"`
+}
+
+// ListUsers returns one page of users, oldest first
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize <= 0 {
+		pageSize = 20 // Default page size
+	} else if pageSize > 100 {
+		pageSize = 100 // Max page size
+	}
+
+	users, total, err := h.users.ListUsers(r.Context(), pageSize, (page-1)*pageSize)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	totalPages := (total + pageSize - 1) / pageSize
+
+	var response ListUsersResponse
+	response.Data = users
+	response.Pagination.Total = total
+	response.Pagination.Count = len(users)
+	response.Pagination.PerPage = pageSize
+	response.Pagination.CurrentPage = page
+	response.Pagination.TotalPages = totalPages
+	response.Pagination.HasMore = page < totalPages
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// SetRole changes a user's role
+func (h *AdminHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	if fields := validateRequest(req); len(fields) > 0 {
+		writeError(w, r, validationError(fields))
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if isCaller(r, id) {
+		writeError(w, r, conflict(CodeConflict, "admins cannot change their own role"))
+		return
+	}
+
+	before, err := h.users.GetUserByID(r.Context(), id)
+	if err != nil {
+		h.writeUserError(w, r, err)
+		return
+	}
+	if err := h.users.SetUserRole(r.Context(), id, req.Role); err != nil {
+		h.writeUserError(w, r, err)
+		return
+	}
+	h.auditor.record(r, models.NewAuditEvent(models.AuditUserRole, models.AuditTargetUser, id).WithChange("role", before.Role, req.Role))
+	h.writeUser(w, r, id)
+}
+
+// DisableUser blocks a user from signing in or using existing tokens and API keys
+func (h *AdminHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if isCaller(r, id) {
+		writeError(w, r, conflict(CodeConflict, "admins cannot disable their own account"))
+		return
+	}
+
+	now := time.Now()
+	if err := h.users.SetUserDisabled(r.Context(), id, &now); err != nil {
+		h.writeUserError(w, r, err)
+		return
+	}
+	// Refresh tokens are revoked outright so re-enabling requires a new login
+	if err := h.tokens.RevokeUserRefreshTokens(r.Context(), id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.auditor.record(r, models.NewAuditEvent(models.AuditUserDisable, models.AuditTargetUser, id))
+	h.writeUser(w, r, id)
+}
+
+// EnableUser lifts a previous DisableUser
+func (h *AdminHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if err := h.users.SetUserDisabled(r.Context(), id, nil); err != nil {
+		h.writeUserError(w, r, err)
+		return
+	}
+	h.auditor.record(r, models.NewAuditEvent(models.AuditUserEnable, models.AuditTargetUser, id))
+	h.writeUser(w, r, id)
+}
+
+// UnlockUser lifts a lockout from failed logins on the user's email address.
+// Lockouts on IP addresses are left to expire.
+func (h *AdminHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	user, err := h.users.GetUserByID(r.Context(), id)
+	if err != nil {
+		h.writeUserError(w, r, err)
+		return
+	}
+
+	if err := h.limiter.Unlock(r.Context(), user.Email); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.auditor.record(r, models.NewAuditEvent(models.AuditUserUnlock, models.AuditTargetUser, id))
+	h.writeUser(w, r, id)
+}
+
+// ResetMFA turns off a user's two-factor authentication, for users who lost both
+// their authenticator and their recovery codes
+func (h *AdminHandler) ResetMFA(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, err := h.users.GetUserByID(r.Context(), id); err != nil {
+		h.writeUserError(w, r, err)
+		return
+	}
+
+	if err := h.mfa.DisableTOTP(r.Context(), id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.auditor.record(r, models.NewAuditEvent(models.AuditUserMFAReset, models.AuditTargetUser, id))
+	h.writeUser(w, r, id)
+}
+
+// ListUserOrganizations returns the organizations a user belongs to and their role in each
+func (h *AdminHandler) ListUserOrganizations(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, err := h.users.GetUserByID(r.Context(), id); err != nil {
+		h.writeUserError(w, r, err)
+		return
+	}
+
+	memberships, err := h.orgs.ListMemberships(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": memberships,
+	})
+}
+
+// writeUser responds with the user's current state
+func (h *AdminHandler) writeUser(w http.ResponseWriter, r *http.Request, id string) {
+	user, err := h.users.GetUserByID(r.Context(), id)
+	if err != nil {
+		h.writeUserError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+func (h *AdminHandler) writeUserError(w http.ResponseWriter, r *http.Request, err error) {
+	if err == store.ErrNotFound {
+		err = notFound("user not found")
+	}
+	writeError(w, r, err)
+}
+
+// isCaller reports whether id is the authenticated user, so admins cannot lock themselves out
+func isCaller(r *http.Request, id string) bool {
+	userID, _ := auth.UserFromContext(r.Context())
+	return userID == id
+}
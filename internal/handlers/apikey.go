@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"transaction-logger/internal/auth"
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+)
+
+type APIKeyHandler struct {
+	keys    store.APIKeyStore
+	auditor *Auditor
+}
+
+func NewAPIKeyHandler(keys store.APIKeyStore, auditor *Auditor) *APIKeyHandler {
+	return &APIKeyHandler{keys: keys, auditor: auditor}
+}
+
+// CreateAPIKeyResponse includes the plaintext key, which is never shown again
+type CreateAPIKeyResponse struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKey issues an API key owned by the authenticated user
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	if fields := validateRequest(req); len(fields) > 0 {
+		writeError(w, r, validationError(fields))
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		writeError(w, r, validationError([]FieldError{{Field: "expires_at", Message: "must be in the future"}}))
+		return
+	}
+
+	key, plaintext, err := models.NewAPIKey(userID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := h.keys.CreateAPIKey(r.Context(), key); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.auditor.record(r, models.NewAuditEvent(models.AuditAPIKeyCreate, models.AuditTargetAPIKey, key.ID).WithDiff(nil, key))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CreateAPIKeyResponse{APIKey: *key, Key: plaintext})
+}
+
+// ListAPIKeys returns the authenticated user's API keys without their secrets
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := h.keys.ListAPIKeys(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": keys,
+	})
+}
+
+// RevokeAPIKey permanently disables one of the authenticated user's API keys
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	err := h.keys.RevokeAPIKey(r.Context(), userID, id, time.Now())
+	if err == store.ErrNotFound {
+		writeError(w, r, notFound("API key not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.auditor.record(r, models.NewAuditEvent(models.AuditAPIKeyRevoke, models.AuditTargetAPIKey, id))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requireSession is requireUser for endpoints that API keys may not call, so a
+// leaked key cannot be used to mint or revoke other keys or to change memberships
+func requireSession(w http.ResponseWriter, r *http.Request) (string, bool) {
+	claims, ok := auth.ClaimsFromContext(r.Context())
+	if !ok || claims.UserID == "" {
+		writeError(w, r, unauthorized("missing authenticated user"))
+		return "", false
+	}
+	if claims.APIKeyID != "" {
+		writeError(w, r, forbidden("this endpoint requires a signed-in user, not an API key"))
+		return "", false
+	}
+	return claims.UserID, true
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"transaction-logger/internal/auth"
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+)
+
+// maxAuditUserAgent bounds the user agent stored with each event
+const maxAuditUserAgent = 512
+
+// Auditor records audit events on behalf of the handlers. Recording never fails a
+// request; errors are logged. A nil Auditor records nothing.
+type Auditor struct {
+	events store.AuditStore
+}
+
+func NewAuditor(events store.AuditStore) *Auditor {
+	return &Auditor{events: events}
+}
+
+// record fills in who made the request and from where, then stores e. Fields the
+// caller already set, such as the actor of a login, are kept.
+func (a *Auditor) record(r *http.Request, e *models.AuditEvent) {
+	if a == nil {
+		return
+	}
+
+	ctx := r.Context()
+	if claims, ok := auth.ClaimsFromContext(ctx); ok {
+		if e.ActorID == "" {
+			e.ActorID = claims.UserID
+		}
+		e.APIKeyID = claims.APIKeyID
+	}
+	if org, ok := auth.OrganizationFromContext(ctx); ok && e.OrganizationID == "" {
+		e.OrganizationID = org.ID
+	}
+	e.IP = clientIP(r)
+	e.UserAgent = r.UserAgent()
+	if len(e.UserAgent) > maxAuditUserAgent {
+		e.UserAgent = e.UserAgent[:maxAuditUserAgent]
+	}
+	e.RequestID = RequestIDFromContext(ctx)
+
+	// The event outlives a client that disconnects once it has its response
+	if err := a.events.CreateAuditEvent(context.WithoutCancel(ctx), e); err != nil {
+		log.Printf("[%s] failed to record audit event %s: %v", e.RequestID, e.Action, err)
+	}
+}
+
+// AuditHandler serves the audit trail
+type AuditHandler struct {
+	events store.AuditStore
+}
+
+func NewAuditHandler(events store.AuditStore) *AuditHandler {
+	return &AuditHandler{events: events}
+}
+
+// ListAuditEventsResponse represents the paginated response for audit events
+type ListAuditEventsResponse struct {
+	Data       []models.AuditEvent `json:"data"`
+	Pagination struct {
+		Total       int  `json:"total"`
+		Count       int  `json:"count"`
+		PerPage     int  `json:"per_page"`
+		CurrentPage int  `json:"current_page"`
+		TotalPages  int  `json:"total_pages"`
+		HasMore     bool `json:"has_more"`
+	} `json:"pagination"`
+}
+
+// List returns one page of audit events, newest first. Staff with the audit:read
+// permission see every event; other users only see events they took or that
+// targeted them, without the IP and user agent of anyone else who acted.
+func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	filter, err := models.ParseAuditFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+	claims, _ := auth.ClaimsFromContext(r.Context())
+	staff := claims.Can(auth.PermReadAudit)
+	if !staff {
+		filter.UserID = userID
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize <= 0 {
+		pageSize = 20 // Default page size
+	} else if pageSize > 100 {
+		pageSize = 100 // Max page size
+	}
+
+	events, total, err := h.events.ListAuditEvents(r.Context(), filter, pageSize, (page-1)*pageSize)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if !staff {
+		for i := range events {
+			// Events with no identified actor, such as failed logins, keep theirs
+			if events[i].ActorID != "" && events[i].ActorID != userID {
+				events[i].IP, events[i].UserAgent = "", ""
+			}
+		}
+	}
+
+	totalPages := (total + pageSize - 1) / pageSize
+
+	var response ListAuditEventsResponse
+	response.Data = events
+	response.Pagination.Total = total
+	response.Pagination.Count = len(events)
+	response.Pagination.PerPage = pageSize
+	response.Pagination.CurrentPage = page
+	response.Pagination.TotalPages = totalPages
+	response.Pagination.HasMore = page < totalPages
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -1,125 +1,483 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
 	"log"
 	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
 
 	"transaction-logger/internal/auth"
+	"transaction-logger/internal/mail"
 	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
 )
 
 type AuthHandler struct {
-	db *sql.DB
+	users   store.UserStore
+	tokens  store.TokenStore
+	mailer  mail.Mailer
+	links   mail.Links
+	limiter *LoginLimiter
+	auditor *Auditor
+}
+
+// NewAuthHandler returns an AuthHandler that sends verification and password reset
+// emails through mailer, which may be nil to send nothing, and throttles failed
+// logins with limiter, which may be nil to allow every attempt
+func NewAuthHandler(users store.UserStore, tokens store.TokenStore, mailer mail.Mailer, links mail.Links, limiter *LoginLimiter, auditor *Auditor) *AuthHandler {
+	return &AuthHandler{users: users, tokens: tokens, mailer: mailer, links: links, limiter: limiter, auditor: auditor}
+}
+
+// TokenResponse is returned by every endpoint that issues credentials
+type TokenResponse struct {
+	Token        string       `json:"token"`
+	RefreshToken string       `json:"refresh_token"`
+	ExpiresIn    int          `json:"expires_in"` // access token lifetime in seconds
+	User         *models.User `json:"user,omitempty"`
 }
 
-func NewAuthHandler(db *sql.DB) *AuthHandler {
-	return &AuthHandler{db: db}
+// MFAChallengeResponse is returned by Login instead of tokens when the user has
+// two-factor authentication enabled
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"` // challenge lifetime in seconds
 }
 
 // Register handles user registration
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var req models.RegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	if fields := validateRequest(req); len(fields) > 0 {
+		writeError(w, r, validationError(fields))
 		return
 	}
 
 	// Check if user already exists
-	_, err := models.GetUserByEmail(h.db, req.Email)
+	_, err := h.users.GetUserByEmail(r.Context(), req.Email)
 	if err == nil {
-		http.Error(w, "email already in use", http.StatusBadRequest)
+		writeError(w, r, conflict(CodeAlreadyExists, "email already in use"))
+		return
+	}
+	if err != store.ErrNotFound {
+		writeError(w, r, err)
 		return
 	}
 
 	// Create new user
-	user, err := models.CreateUser(h.db, req.Email, req.Password)
+	user, err := models.NewUser(req.Email, req.Password)
 	if err != nil {
-		log.Printf("Error creating user: %v", err)
-		http.Error(w, "error creating user", http.StatusInternalServerError)
+		writeError(w, r, err)
+		return
+	}
+	if err := h.users.CreateUser(r.Context(), user); err != nil {
+		writeError(w, r, err)
 		return
 	}
+	h.auditor.record(r, models.NewAuditEvent(models.AuditRegister, models.AuditTargetUser, user.ID).WithActor(user.ID))
+
+	// The account is usable straight away; verification is only needed to accept invitations
+	if msg, err := h.newUserToken(r, user, models.TokenPurposeVerifyEmail); err != nil {
+		log.Printf("[%s] failed to create verification token for %s: %v", RequestIDFromContext(r.Context()), user.ID, err)
+	} else {
+		sendMailAsync(r, h.mailer, msg)
+	}
 
-	// Generate JWT token
-	token, err := auth.GenerateJWT(user.ID, user.Email)
+	// Start a new refresh token family for this session
+	resp, err := issueTokens(r, h.tokens, user)
 	if err != nil {
-		log.Printf("Error generating token: %v", err)
-		http.Error(w, "error generating token", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	// Return token and user info (without password)
-	user.Password = ""
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"token": token,
-		"user":  user,
-	})
+	json.NewEncoder(w).Encode(resp)
 }
 
 // Login handles user login
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req models.LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	if fields := validateRequest(req); len(fields) > 0 {
+		writeError(w, r, validationError(fields))
+		return
+	}
+
+	// Locked emails and IPs are refused before the password is even checked
+	if err := h.limiter.check(r, req.Email); err != nil {
+		h.auditor.record(r, models.NewAuditEvent(models.AuditLoginFailed, "", "").
+			WithMetadata("email", req.Email).WithMetadata("reason", "locked"))
+		writeError(w, r, err)
 		return
 	}
 
 	// Get user by email
-	user, err := models.GetUserByEmail(h.db, req.Email)
+	user, err := h.users.GetUserByEmail(r.Context(), req.Email)
+	if err == store.ErrNotFound {
+		// Spend as long as a real password check so response times do not reveal the account
+		models.CheckPassword(dummyPasswordHash(), req.Password)
+		h.limiter.fail(r, req.Email)
+		h.auditor.record(r, models.NewAuditEvent(models.AuditLoginFailed, "", "").
+			WithMetadata("email", req.Email).WithMetadata("reason", "unknown_email"))
+		writeError(w, r, unauthorized("invalid credentials"))
+		return
+	}
 	if err != nil {
-		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		writeError(w, r, err)
 		return
 	}
 
 	// Check password
 	if err := models.CheckPassword(user.Password, req.Password); err != nil {
-		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		h.limiter.fail(r, req.Email)
+		h.auditor.record(r, models.NewAuditEvent(models.AuditLoginFailed, models.AuditTargetUser, user.ID).
+			WithMetadata("reason", "bad_password"))
+		writeError(w, r, unauthorized("invalid credentials"))
 		return
 	}
 
-	// Generate JWT token
-	token, err := auth.GenerateJWT(user.ID, user.Email)
+	if user.DisabledAt != nil {
+		h.auditor.record(r, models.NewAuditEvent(models.AuditLoginFailed, models.AuditTargetUser, user.ID).
+			WithMetadata("reason", "disabled"))
+		writeError(w, r, forbidden("account is disabled"))
+		return
+	}
+
+	// With two-factor authentication the tokens come from MFAHandler.Verify instead
+	if user.TOTPEnabledAt != nil {
+		challenge, err := auth.GenerateMFAChallenge(user.ID)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		h.auditor.record(r, models.NewAuditEvent(models.AuditLoginChallenge, models.AuditTargetUser, user.ID).WithActor(user.ID))
+		json.NewEncoder(w).Encode(MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    challenge,
+			ExpiresIn:   int(auth.MFAChallengeTTL.Seconds()),
+		})
+		return
+	}
+
+	// Start a new refresh token family for this session
+	resp, err := issueTokens(r, h.tokens, user)
 	if err != nil {
-		log.Printf("Error generating token: %v", err)
-		http.Error(w, "error generating token", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+	h.limiter.succeed(r, req.Email)
+	h.auditor.record(r, models.NewAuditEvent(models.AuditLogin, models.AuditTargetUser, user.ID).WithActor(user.ID))
 
-	// Return token and user info (without password)
-	user.Password = ""
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"token": token,
-		"user":  user,
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Refresh exchanges a refresh token for a new access token and refresh token.
+// Each refresh token works once; presenting a used one revokes its whole family,
+// since it means the token was copied.
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	if fields := validateRequest(req); len(fields) > 0 {
+		writeError(w, r, validationError(fields))
+		return
+	}
+
+	current, err := h.tokens.GetRefreshToken(r.Context(), models.HashToken(req.RefreshToken))
+	if err == store.ErrNotFound {
+		writeError(w, r, unauthorized("invalid refresh token"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if current.UsedAt != nil {
+		h.revokeFamily(r, current)
+		writeError(w, r, unauthorized("refresh token reuse detected; please log in again"))
+		return
+	}
+	if current.RevokedAt != nil || current.Expired(time.Now()) {
+		writeError(w, r, unauthorized("refresh token expired or revoked"))
+		return
+	}
+
+	user, err := h.users.GetUserByID(r.Context(), current.UserID)
+	if err == store.ErrNotFound {
+		writeError(w, r, unauthorized("invalid refresh token"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if user.DisabledAt != nil {
+		writeError(w, r, forbidden("account is disabled"))
+		return
+	}
+
+	next, plaintext, err := models.NewRefreshToken(user.ID, current.FamilyID, auth.RefreshTokenTTL())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Losing the race to another request presenting the same token is also reuse
+	err = h.tokens.RotateRefreshToken(r.Context(), current.ID, next)
+	if err == store.ErrConflict {
+		h.revokeFamily(r, current)
+		writeError(w, r, unauthorized("refresh token reuse detected; please log in again"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	token, err := auth.GenerateJWT(user.ID, user.Email, user.Role)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.auditor.record(r, models.NewAuditEvent(models.AuditRefresh, models.AuditTargetUser, user.ID).WithActor(user.ID))
+
+	json.NewEncoder(w).Encode(TokenResponse{
+		Token:        token,
+		RefreshToken: plaintext,
+		ExpiresIn:    int(auth.AccessTokenTTL().Seconds()),
 	})
 }
 
-// AuthMiddleware verifies the JWT token
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Skip auth for login and register endpoints
-		if r.URL.Path == "/api/auth/register" || r.URL.Path == "/api/auth/login" {
-			next.ServeHTTP(w, r)
+// Logout revokes the caller's access token and, if given, the refresh token's family
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := auth.ClaimsFromContext(r.Context())
+	if !ok {
+		writeError(w, r, unauthorized("missing authenticated user"))
+		return
+	}
+
+	// The body is optional
+	var req models.LogoutRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, badRequest(err.Error()))
 			return
 		}
+	}
 
-		tokenString := r.Header.Get("Authorization")
-		if tokenString == "" {
-			http.Error(w, "missing authorization header", http.StatusUnauthorized)
+	if req.RefreshToken != "" {
+		token, err := h.tokens.GetRefreshToken(r.Context(), models.HashToken(req.RefreshToken))
+		if err != nil && err != store.ErrNotFound {
+			writeError(w, r, err)
 			return
 		}
+		// Never revoke another user's session
+		if err == nil && token.UserID == claims.UserID {
+			if err := h.tokens.RevokeTokenFamily(r.Context(), token.FamilyID); err != nil {
+				writeError(w, r, err)
+				return
+			}
+		}
+	}
 
-		// Remove "Bearer " prefix if present
-		if len(tokenString) > 7 && tokenString[:7] == "Bearer " {
-			tokenString = tokenString[7:]
+	if claims.ID != "" && claims.ExpiresAt != nil {
+		if err := h.tokens.RevokeAccessToken(r.Context(), claims.ID, claims.ExpiresAt.Time); err != nil {
+			writeError(w, r, err)
+			return
 		}
+	}
+	h.auditor.record(r, models.NewAuditEvent(models.AuditLogout, models.AuditTargetUser, claims.UserID))
 
-		_, err := auth.ValidateToken(tokenString)
-		if err != nil {
-			http.Error(w, "invalid token", http.StatusUnauthorized)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// issueTokens creates an access token and the first refresh token of a new family
+func issueTokens(r *http.Request, tokens store.TokenStore, user *models.User) (*TokenResponse, error) {
+	token, err := auth.GenerateJWT(user.ID, user.Email, user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, plaintext, err := models.NewRefreshToken(user.ID, "", auth.RefreshTokenTTL())
+	if err != nil {
+		return nil, err
+	}
+	if err := tokens.CreateRefreshToken(r.Context(), refresh); err != nil {
+		return nil, err
+	}
+
+	// Return user info without the password hash
+	user.Password = ""
+	return &TokenResponse{
+		Token:        token,
+		RefreshToken: plaintext,
+		ExpiresIn:    int(auth.AccessTokenTTL().Seconds()),
+		User:         user,
+	}, nil
+}
+
+// revokeFamily revokes the family of a reused refresh token, logging rather than
+// failing the request since the caller is rejected either way
+func (h *AuthHandler) revokeFamily(r *http.Request, reused *models.RefreshToken) {
+	h.auditor.record(r, models.NewAuditEvent(models.AuditRefreshReuse, models.AuditTargetUser, reused.UserID).
+		WithMetadata("family_id", reused.FamilyID))
+	if err := h.tokens.RevokeTokenFamily(r.Context(), reused.FamilyID); err != nil {
+		log.Printf("[%s] failed to revoke token family %s: %v", RequestIDFromContext(r.Context()), reused.FamilyID, err)
+	}
+}
+
+// publicAuthPaths are the /api/auth endpoints that take no credentials
+var publicAuthPaths = map[string]bool{
+	"/api/auth/register":        true,
+	"/api/auth/login":           true,
+	"/api/auth/refresh":         true,
+	"/api/auth/mfa/verify":      true,
+	"/api/auth/verify-email":    true,
+	"/api/auth/password/forgot": true,
+	"/api/auth/password/reset":  true,
+}
+
+// apiKeyTouchInterval limits how often a key's last-used time is written
+const apiKeyTouchInterval = time.Minute
+
+// AuthMiddleware authenticates requests with either a Bearer JWT or, when
+// apiKeys is not nil, an X-API-Key header. When users is not nil the caller's
+// account is loaded on every request, so disabling a user or changing their
+// role takes effect immediately rather than when their token expires.
+func AuthMiddleware(users store.UserStore, apiKeys store.APIKeyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Skip auth for the public auth endpoints
+			if publicAuthPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var claims *auth.Claims
+			if key := r.Header.Get("X-API-Key"); key != "" && apiKeys != nil {
+				var err error
+				if claims, err = authenticateAPIKey(r, apiKeys, key); err != nil {
+					writeError(w, r, err)
+					return
+				}
+			} else {
+				tokenString := r.Header.Get("Authorization")
+				if tokenString == "" {
+					writeError(w, r, unauthorized("missing authorization header"))
+					return
+				}
+
+				// Remove "Bearer " prefix if present
+				if len(tokenString) > 7 && tokenString[:7] == "Bearer " {
+					tokenString = tokenString[7:]
+				}
+
+				var err error
+				if claims, err = auth.ValidateToken(tokenString); err != nil {
+					writeError(w, r, unauthorized("invalid token"))
+					return
+				}
+			}
+
+			if users != nil {
+				user, err := users.GetUserByID(r.Context(), claims.UserID)
+				if err == store.ErrNotFound {
+					writeError(w, r, unauthorized("user no longer exists"))
+					return
+				}
+				if err != nil {
+					writeError(w, r, err)
+					return
+				}
+				if user.DisabledAt != nil {
+					writeError(w, r, forbidden("account is disabled"))
+					return
+				}
+				claims.Role = user.Role
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+// authenticateAPIKey resolves an X-API-Key header to the owner's claims
+func authenticateAPIKey(r *http.Request, apiKeys store.APIKeyStore, plaintext string) (*auth.Claims, error) {
+	key, err := apiKeys.GetAPIKeyByHash(r.Context(), models.HashToken(plaintext))
+	if err == store.ErrNotFound {
+		return nil, unauthorized("invalid API key")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !key.Usable(now) {
+		return nil, unauthorized("API key expired or revoked")
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := apiKeys.TouchAPIKey(r.Context(), key.ID, now); err != nil {
+			log.Printf("[%s] failed to record use of API key %s: %v", RequestIDFromContext(r.Context()), key.ID, err)
+		}
+	}
+
+	return &auth.Claims{UserID: key.UserID, APIKeyID: key.ID, Scopes: key.Scopes}, nil
+}
+
+// RequirePermission is gorilla/mux middleware rejecting callers whose role lacks permission
+func RequirePermission(permission string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := auth.ClaimsFromContext(r.Context())
+			if !ok {
+				writeError(w, r, unauthorized("missing authenticated user"))
+				return
+			}
+			if !claims.Can(permission) {
+				writeError(w, r, forbidden("missing permission "+permission))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireScope rejects callers whose API key was not granted scope
+func RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := auth.ClaimsFromContext(r.Context())
+		if !ok {
+			writeError(w, r, unauthorized("missing authenticated user"))
+			return
+		}
+		if !claims.HasScope(scope) {
+			writeError(w, r, forbidden("API key lacks the "+scope+" scope"))
 			return
 		}
+		next(w, r)
+	}
+}
 
-		next.ServeHTTP(w, r)
-	})
+// requireUser returns the authenticated user's ID, writing a 401 if the
+// request carries no identity
+func requireUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, r, unauthorized("missing authenticated user"))
+	}
+	return userID, ok
 }
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"transaction-logger/internal/chain"
+	"transaction-logger/internal/store"
+)
+
+// ChainHandler exposes the hash chain over the active organization's transactions
+type ChainHandler struct {
+	chains store.ChainStore
+	signer *chain.Signer
+}
+
+func NewChainHandler(chains store.ChainStore, signer *chain.Signer) *ChainHandler {
+	return &ChainHandler{chains: chains, signer: signer}
+}
+
+// VerifyChain walks the active organization's chain and reports the first broken
+// link. A broken chain is still a 200; the report says so.
+func (h *ChainHandler) VerifyChain(w http.ResponseWriter, r *http.Request) {
+	// Get the active organization (set by OrganizationMiddleware)
+	orgID, ok := requireOrganization(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := chain.Verify(r.Context(), h.chains, h.signer, orgID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// ListCheckpoints returns the active organization's signed checkpoints, newest first
+func (h *ChainHandler) ListCheckpoints(w http.ResponseWriter, r *http.Request) {
+	// Get the active organization (set by OrganizationMiddleware)
+	orgID, ok := requireOrganization(w, r)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	checkpoints, err := h.chains.ListChainCheckpoints(r.Context(), orgID, limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":       checkpoints,
+		"public_key": h.signer.PublicKey(),
+		"key_id":     h.signer.KeyID(),
+	})
+}
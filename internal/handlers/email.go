@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"transaction-logger/internal/mail"
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+)
+
+const (
+	// verifyEmailTTL is how long an email verification link works
+	verifyEmailTTL = 48 * time.Hour
+	// resetPasswordTTL is how long a password reset link works
+	resetPasswordTTL = time.Hour
+	// mailTimeout bounds emails sent after the response has been written
+	mailTimeout = 30 * time.Second
+)
+
+// ResendVerification emails the caller a new verification link, replacing any earlier one
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.users.GetUserByID(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		writeError(w, r, conflict(CodeConflict, "email is already verified"))
+		return
+	}
+
+	msg, err := h.newUserToken(r, user, models.TokenPurposeVerifyEmail)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := sendMail(r.Context(), h.mailer, msg); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.auditor.record(r, models.NewAuditEvent(models.AuditVerificationSent, models.AuditTargetUser, user.ID))
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// VerifyEmail redeems a verification token
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req models.VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	if fields := validateRequest(req); len(fields) > 0 {
+		writeError(w, r, validationError(fields))
+		return
+	}
+
+	now := time.Now()
+	token, ok := h.consumeUserToken(w, r, req.Token, models.TokenPurposeVerifyEmail, now)
+	if !ok {
+		return
+	}
+	if err := h.users.SetEmailVerified(r.Context(), token.UserID, now); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.auditor.record(r, models.NewAuditEvent(models.AuditEmailVerified, models.AuditTargetUser, token.UserID).WithActor(token.UserID))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ForgotPassword emails a password reset link. It answers 202 whether or not the
+// address belongs to an account, and sends in the background so response times
+// do not reveal it either.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	if fields := validateRequest(req); len(fields) > 0 {
+		writeError(w, r, validationError(fields))
+		return
+	}
+
+	user, err := h.users.GetUserByEmail(r.Context(), req.Email)
+	if err != nil && err != store.ErrNotFound {
+		writeError(w, r, err)
+		return
+	}
+	if err == nil && user.DisabledAt == nil {
+		msg, err := h.newUserToken(r, user, models.TokenPurposeResetPassword)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		sendMailAsync(r, h.mailer, msg)
+		h.auditor.record(r, models.NewAuditEvent(models.AuditPasswordResetRequested, models.AuditTargetUser, user.ID))
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword redeems a password reset token and sets a new password. Every
+// refresh token the user holds is revoked, signing out their other sessions.
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	if fields := validateRequest(req); len(fields) > 0 {
+		writeError(w, r, validationError(fields))
+		return
+	}
+
+	// Hash before consuming the token so a failure here leaves it usable
+	hashed, err := models.HashPassword(req.Password)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	token, ok := h.consumeUserToken(w, r, req.Token, models.TokenPurposeResetPassword, now)
+	if !ok {
+		return
+	}
+	if err := h.users.SetUserPassword(r.Context(), token.UserID, hashed); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	// Receiving the email proves the user controls the address
+	if err := h.users.SetEmailVerified(r.Context(), token.UserID, now); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := h.tokens.RevokeUserRefreshTokens(r.Context(), token.UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.auditor.record(r, models.NewAuditEvent(models.AuditPasswordReset, models.AuditTargetUser, token.UserID).WithActor(token.UserID))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// newUserToken stores a fresh token for purpose and returns the email carrying it
+func (h *AuthHandler) newUserToken(r *http.Request, user *models.User, purpose string) (mail.Message, error) {
+	ttl := verifyEmailTTL
+	if purpose == models.TokenPurposeResetPassword {
+		ttl = resetPasswordTTL
+	}
+
+	token, plaintext, err := models.NewUserToken(user.ID, purpose, ttl)
+	if err != nil {
+		return mail.Message{}, err
+	}
+	if err := h.tokens.CreateUserToken(r.Context(), token); err != nil {
+		return mail.Message{}, err
+	}
+
+	if purpose == models.TokenPurposeResetPassword {
+		return h.links.ResetPassword(user.Email, plaintext), nil
+	}
+	return h.links.VerifyEmail(user.Email, plaintext), nil
+}
+
+// consumeUserToken redeems a token, writing a 400 if it is unknown, used or expired
+func (h *AuthHandler) consumeUserToken(w http.ResponseWriter, r *http.Request, plaintext, purpose string, at time.Time) (*models.UserToken, bool) {
+	token, err := h.tokens.ConsumeUserToken(r.Context(), models.HashToken(plaintext), purpose, at)
+	if err == store.ErrNotFound {
+		writeError(w, r, badRequest("invalid or expired token"))
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return nil, false
+	}
+	return token, true
+}
+
+// sendMail delivers msg; a nil mailer sends nothing
+func sendMail(ctx context.Context, mailer mail.Mailer, msg mail.Message) error {
+	if mailer == nil {
+		return nil
+	}
+	return mailer.Send(ctx, msg)
+}
+
+// sendMailAsync delivers msg after the handler returns, logging failures
+func sendMailAsync(r *http.Request, mailer mail.Mailer, msg mail.Message) {
+	requestID := RequestIDFromContext(r.Context())
+	ctx := context.WithoutCancel(r.Context())
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, mailTimeout)
+		defer cancel()
+		if err := sendMail(ctx, mailer, msg); err != nil {
+			log.Printf("[%s] failed to send %q email: %v", requestID, msg.Subject, err)
+		}
+	}()
+}
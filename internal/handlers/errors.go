@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+)
+
+// Machine-readable error codes returned in the "code" member of a problem
+const (
+	CodeBadRequest          = "bad_request"
+	CodeValidationFailed    = "validation_failed"
+	CodeUnauthorized        = "unauthorized"
+	CodeForbidden           = "forbidden"
+	CodeNotFound            = "not_found"
+	CodeMethodNotAllowed    = "method_not_allowed"
+	CodeConflict            = "conflict"
+	CodeAlreadyExists       = "already_exists"
+	CodeInvalidReference    = "invalid_reference"
+	CodeInvalidTransition   = "invalid_status_transition"
+	CodeIdempotencyMismatch = "idempotency_key_mismatch"
+	CodeTooManyRequests     = "too_many_requests"
+	CodeInternal            = "internal_error"
+)
+
+// problemTypeBase prefixes every error code to form the problem "type" URI
+const problemTypeBase = "urn:transaction-logger:problem:"
+
+// Problem is an RFC 7807 problem details response body
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	TraceID  string       `json:"trace_id,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// APIError is an error that knows how it should be reported to clients
+type APIError struct {
+	Status int
+	Code   string
+	Detail string
+	Fields []FieldError
+	// RetryAfter, when set, is sent as the Retry-After header
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return e.Detail
+}
+
+func newAPIError(status int, code, detail string) *APIError {
+	return &APIError{Status: status, Code: code, Detail: detail}
+}
+
+func badRequest(detail string) *APIError {
+	return newAPIError(http.StatusBadRequest, CodeBadRequest, detail)
+}
+
+func unauthorized(detail string) *APIError {
+	return newAPIError(http.StatusUnauthorized, CodeUnauthorized, detail)
+}
+
+func forbidden(detail string) *APIError {
+	return newAPIError(http.StatusForbidden, CodeForbidden, detail)
+}
+
+func notFound(detail string) *APIError {
+	return newAPIError(http.StatusNotFound, CodeNotFound, detail)
+}
+
+func methodNotAllowed(detail string) *APIError {
+	return newAPIError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, detail)
+}
+
+func conflict(code, detail string) *APIError {
+	return newAPIError(http.StatusConflict, code, detail)
+}
+
+func tooManyRequests(detail string, retryAfter time.Duration) *APIError {
+	err := newAPIError(http.StatusTooManyRequests, CodeTooManyRequests, detail)
+	err.RetryAfter = retryAfter
+	return err
+}
+
+// routeMethods are tried against the router when a request matches no route
+var routeMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+// Unrouted returns a handler for requests that match no route in router. When
+// router serves the path under other methods the answer is a 405 listing them in
+// Allow, otherwise a 404. Routes on a subrouter hide method mismatches from mux,
+// so the methods are checked here rather than left to it.
+func Unrouted(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var allowed []string
+		for _, method := range routeMethods {
+			probe := *r
+			probe.Method = method
+			var match mux.RouteMatch
+			if router.Match(&probe, &match) && match.MatchErr == nil {
+				allowed = append(allowed, method)
+			}
+		}
+
+		if len(allowed) == 0 {
+			writeError(w, r, notFound("no route matches "+r.URL.Path))
+			return
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, r, methodNotAllowed(r.Method+" is not allowed on "+r.URL.Path))
+	})
+}
+
+// writeError writes err as an application/problem+json response. Errors that are
+// not an *APIError are mapped from known database errors; anything else is logged
+// and reported as a 500 without exposing the underlying message.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := toAPIError(err)
+	traceID := RequestIDFromContext(r.Context())
+
+	if apiErr.Status >= http.StatusInternalServerError {
+		log.Printf("[%s] %s %s: %v", traceID, r.Method, r.URL.Path, err)
+	}
+
+	if apiErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(apiErr.RetryAfter)))
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(Problem{
+		Type:     problemTypeBase + apiErr.Code,
+		Title:    http.StatusText(apiErr.Status),
+		Status:   apiErr.Status,
+		Detail:   apiErr.Detail,
+		Instance: r.URL.Path,
+		Code:     apiErr.Code,
+		TraceID:  traceID,
+		Errors:   apiErr.Fields,
+	})
+}
+
+func toAPIError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, store.ErrNotFound):
+		return notFound("resource not found")
+	case errors.Is(err, store.ErrAlreadyExists):
+		return conflict(CodeAlreadyExists, "resource already exists")
+	case errors.Is(err, store.ErrConflict):
+		return conflict(CodeConflict, "resource was modified concurrently")
+	case errors.Is(err, models.ErrAccountNotFound):
+		return notFound(err.Error())
+	case errors.Is(err, models.ErrCurrencyMismatch):
+		return badRequest(err.Error())
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Name() {
+		case "unique_violation":
+			return conflict(CodeAlreadyExists, "resource already exists")
+		case "foreign_key_violation":
+			return newAPIError(http.StatusUnprocessableEntity, CodeInvalidReference, "referenced resource does not exist")
+		}
+	}
+
+	return newAPIError(http.StatusInternalServerError, CodeInternal, "internal server error")
+}
+
+// retryAfterSeconds rounds d up to whole seconds, as Retry-After requires
+func retryAfterSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
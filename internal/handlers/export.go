@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"transaction-logger/internal/export"
+	"transaction-logger/internal/models"
+)
+
+// ExportTransactions streams every transaction matching the listing filters as a
+// file download. The format comes from the format query parameter (csv, ndjson or
+// xlsx) or, failing that, the Accept header, and defaults to CSV.
+func (h *TransactionHandler) ExportTransactions(w http.ResponseWriter, r *http.Request) {
+	// Get the active organization (set by OrganizationMiddleware)
+	orgID, ok := requireOrganization(w, r)
+	if !ok {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = negotiateExportFormat(r.Header.Get("Accept"))
+	}
+	if export.ContentType(format) == "" {
+		writeError(w, r, badRequest("unsupported export format: "+format))
+		return
+	}
+
+	filter, err := models.ParseTransactionFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+	filename := "transactions-" + time.Now().UTC().Format("20060102") + "." + format
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+
+	out, err := export.NewWriter(format, w)
+	if err != nil {
+		abortExport(r, err)
+	}
+
+	err = h.store.ExportTransactions(r.Context(), orgID, filter, out.Write)
+	if err != nil {
+		abortExport(r, err)
+	}
+
+	if err := out.Close(); err != nil {
+		abortExport(r, err)
+	}
+}
+
+// abortExport logs a failure after the response has started and drops the
+// connection, so the client sees a truncated download rather than a valid file
+func abortExport(r *http.Request, err error) {
+	log.Printf("[%s] export failed: %v", RequestIDFromContext(r.Context()), err)
+	panic(http.ErrAbortHandler)
+}
+
+// negotiateExportFormat picks the first supported format listed in an Accept header
+func negotiateExportFormat(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if format := export.FormatForMediaType(mediaType); format != "" {
+			return format
+		}
+	}
+	return export.FormatCSV
+}
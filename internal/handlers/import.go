@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"transaction-logger/internal/models"
+)
+
+// importBatchSize is the number of rows sent per COPY
+const importBatchSize = 500
+
+// importColumns are the fields an import reads, keyed by their default CSV header
+var importColumns = []string{
+	"timestamp", "sender_account", "receiver_account", "amount",
+	"currency", "transaction_type", "status",
+}
+
+// defaultDateFormats are tried in order when no date_format parameter is given
+var defaultDateFormats = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"01/02/2006",
+}
+
+// ImportReport summarises the outcome of a CSV import
+type ImportReport struct {
+	DryRun   bool              `json:"dry_run"`
+	Accepted int               `json:"accepted"`
+	Rejected int               `json:"rejected"`
+	Rows     []ImportRowResult `json:"rows"`
+}
+
+// ImportRowResult is the outcome for a single CSV line
+type ImportRowResult struct {
+	Line          int          `json:"line"`
+	Status        string       `json:"status"`
+	TransactionID string       `json:"transaction_id,omitempty"`
+	Errors        []FieldError `json:"errors,omitempty"`
+}
+
+type importOptions struct {
+	dryRun      bool
+	delimiter   rune
+	dateFormats []string
+	// headers maps each import column to the CSV header it is read from
+	headers map[string]string
+}
+
+type importRow struct {
+	line int
+	tx   models.Transaction
+}
+
+// ImportTransactions streams a CSV body into the store. Valid rows are inserted in
+// batches that only become visible once the whole import commits; invalid rows are
+// skipped and reported. With dry_run=true everything is checked and rolled back.
+//
+// Query parameters:
+//   - dry_run: validate without saving
+//   - delimiter: single field separator character (default ",")
+//   - date_format: Go time layout for the timestamp column; may be repeated
+//   - column.<field>: CSV header to read <field> from, e.g. column.amount=Value
+func (h *TransactionHandler) ImportTransactions(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context (set by AuthMiddleware)
+	userID, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	// Get the active organization (set by OrganizationMiddleware)
+	orgID, ok := requireOrganization(w, r)
+	if !ok {
+		return
+	}
+
+	opts, err := parseImportOptions(r.URL.Query())
+	if err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	reader := csv.NewReader(r.Body)
+	reader.Comma = opts.delimiter
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		writeError(w, r, badRequest("could not read CSV header"))
+		return
+	}
+	index, err := opts.columnIndex(header)
+	if err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	imp, err := h.store.BeginImport(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	defer imp.Rollback()
+
+	report := ImportReport{DryRun: opts.dryRun, Rows: []ImportRowResult{}}
+	batch := make([]importRow, 0, importBatchSize)
+
+	flush := func() error {
+		var accepted []models.Transaction
+		for _, row := range batch {
+			if err := imp.Check(r.Context(), &row.tx); err != nil {
+				if !errors.Is(err, models.ErrCurrencyMismatch) {
+					return err
+				}
+				report.reject(row.line, FieldError{Field: "currency", Message: err.Error()})
+				continue
+			}
+			accepted = append(accepted, row.tx)
+			report.accept(row.line, row.tx.ID)
+		}
+		batch = batch[:0]
+
+		if len(accepted) == 0 {
+			return nil
+		}
+		return imp.Insert(r.Context(), accepted)
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			report.reject(parseErr.Line, FieldError{Message: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			writeError(w, r, badRequest("could not read CSV: "+err.Error()))
+			return
+		}
+		// FieldPos is only valid after a successful Read
+		line, _ := reader.FieldPos(0)
+
+		tx, fields := opts.buildTransaction(record, index, orgID, userID)
+		if len(fields) > 0 {
+			report.reject(line, fields...)
+			continue
+		}
+
+		batch = append(batch, importRow{line: line, tx: tx})
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				writeError(w, r, err)
+				return
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if !opts.dryRun {
+		if err := imp.Commit(); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		h.auditor.record(r, models.NewAuditEvent(models.AuditTransactionImport, "", "").
+			WithMetadata("accepted", report.Accepted).WithMetadata("rejected", report.Rejected))
+	}
+
+	sort.Slice(report.Rows, func(i, j int) bool { return report.Rows[i].Line < report.Rows[j].Line })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func (rep *ImportReport) accept(line int, id string) {
+	rep.Accepted++
+	rep.Rows = append(rep.Rows, ImportRowResult{Line: line, Status: "accepted", TransactionID: id})
+}
+
+func (rep *ImportReport) reject(line int, fields ...FieldError) {
+	rep.Rejected++
+	rep.Rows = append(rep.Rows, ImportRowResult{Line: line, Status: "rejected", Errors: fields})
+}
+
+func parseImportOptions(q url.Values) (importOptions, error) {
+	opts := importOptions{
+		dryRun:      q.Get("dry_run") == "true",
+		delimiter:   ',',
+		dateFormats: q["date_format"],
+		headers:     make(map[string]string, len(importColumns)),
+	}
+	if len(opts.dateFormats) == 0 {
+		opts.dateFormats = defaultDateFormats
+	}
+
+	if d := q.Get("delimiter"); d != "" {
+		if utf8.RuneCountInString(d) != 1 {
+			return opts, errors.New("delimiter must be a single character")
+		}
+		opts.delimiter, _ = utf8.DecodeRuneInString(d)
+	}
+
+	for _, column := range importColumns {
+		opts.headers[column] = column
+	}
+	for key, values := range q {
+		column, ok := strings.CutPrefix(key, "column.")
+		if !ok {
+			continue
+		}
+		if _, known := opts.headers[column]; !known {
+			return opts, fmt.Errorf("unknown import column: %s", column)
+		}
+		opts.headers[column] = values[0]
+	}
+
+	return opts, nil
+}
+
+// columnIndex locates each import column in the CSV header. Only status may be absent.
+func (opts importOptions) columnIndex(header []string) (map[string]int, error) {
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		positions[strings.TrimSpace(name)] = i
+	}
+
+	index := make(map[string]int, len(importColumns))
+	for _, column := range importColumns {
+		i, ok := positions[opts.headers[column]]
+		if !ok {
+			if column == "status" {
+				continue
+			}
+			return nil, fmt.Errorf("CSV header is missing column %q for %s", opts.headers[column], column)
+		}
+		index[column] = i
+	}
+	return index, nil
+}
+
+// buildTransaction converts a CSV record into a transaction in orgID created by userID,
+// returning any field errors
+func (opts importOptions) buildTransaction(record []string, index map[string]int, orgID, userID string) (models.Transaction, []FieldError) {
+	value := func(column string) string {
+		i, ok := index[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	req := models.CreateTransactionRequest{
+		SenderAccount:   value("sender_account"),
+		ReceiverAccount: value("receiver_account"),
+		Amount:          json.Number(value("amount")),
+		Currency:        value("currency"),
+		TransactionType: value("transaction_type"),
+		UserID:          userID,
+	}
+	amount, fields := requestAmount(req, validateRequest(req))
+
+	timestamp, ok := parseImportTime(value("timestamp"), opts.dateFormats)
+	if !ok {
+		fields = append(fields, FieldError{Field: "timestamp", Message: "does not match any accepted date format"})
+	}
+
+	status := value("status")
+	if status == "" {
+		status = models.StatusCompleted
+	} else if !models.IsValidStatus(status) {
+		fields = append(fields, FieldError{Field: "status", Message: "is not a valid status"})
+	}
+
+	return models.Transaction{
+		ID:              generateID(),
+		Timestamp:       timestamp,
+		SenderAccount:   req.SenderAccount,
+		ReceiverAccount: req.ReceiverAccount,
+		Amount:          amount,
+		Currency:        req.Currency,
+		TransactionType: req.TransactionType,
+		Status:          status,
+		OrganizationID:  orgID,
+		UserID:          userID,
+	}, fields
+}
+
+func parseImportTime(value string, layouts []string) (time.Time, bool) {
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
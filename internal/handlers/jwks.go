@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"transaction-logger/internal/auth"
+)
+
+// JWKS serves the public halves of the signing keys so other services can verify
+// access tokens. Verifiers should refetch when they see an unknown kid, since a
+// rotated key signs tokens immediately.
+func JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(auth.Keys().JWKS())
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+)
+
+// LoginFailureWindow is how long failed logins are remembered; a success clears them sooner
+const LoginFailureWindow = 24 * time.Hour
+
+// LockoutPolicy decides how long a key is blocked after consecutive failed logins.
+// Once a third of Threshold failures have happened each further failure blocks the
+// key for exponentially longer, starting at a second; at Threshold it is locked
+// for Lockout. A zero Threshold disables the policy.
+type LockoutPolicy struct {
+	Threshold int
+	Lockout   time.Duration
+}
+
+// Delay returns how long to block a key after the given number of failures in a row
+func (p LockoutPolicy) Delay(failures int) time.Duration {
+	start := max(p.Threshold/3, 1)
+	switch {
+	case p.Threshold <= 0 || failures < start:
+		return 0
+	case failures >= p.Threshold || failures-start >= 30:
+		return p.Lockout
+	}
+	return min(time.Second<<(failures-start), p.Lockout)
+}
+
+// LoginLimiter throttles password and second-factor guessing per email address and
+// per client IP. Unknown emails are tracked like real ones so lockouts do not reveal
+// which addresses have accounts. A nil LoginLimiter allows everything.
+type LoginLimiter struct {
+	attempts store.LoginAttemptStore
+	email    LockoutPolicy
+	ip       LockoutPolicy
+}
+
+func NewLoginLimiter(attempts store.LoginAttemptStore, email, ip LockoutPolicy) *LoginLimiter {
+	return &LoginLimiter{attempts: attempts, email: email, ip: ip}
+}
+
+// Unlock lifts the lock on an email address and forgets its failures
+func (l *LoginLimiter) Unlock(ctx context.Context, email string) error {
+	if l == nil {
+		return nil
+	}
+	return l.attempts.ClearLoginFailures(ctx, loginEmailKey(email))
+}
+
+// check returns a 429 error if the email or the caller's IP is locked
+func (l *LoginLimiter) check(r *http.Request, email string) error {
+	if l == nil {
+		return nil
+	}
+	now := time.Now()
+	until, err := l.attempts.LoginLockedUntil(r.Context(), []string{loginEmailKey(email), loginIPKey(r)}, now)
+	if err != nil {
+		return err
+	}
+	if until.After(now) {
+		return tooManyRequests("too many failed login attempts; try again later", until.Sub(now))
+	}
+	return nil
+}
+
+// fail records a failed attempt against the email and the caller's IP. Errors are
+// logged rather than returned since the caller is rejected either way.
+func (l *LoginLimiter) fail(r *http.Request, email string) {
+	if l == nil {
+		return
+	}
+	now := time.Now()
+	for _, k := range []struct {
+		key    string
+		policy LockoutPolicy
+	}{
+		{loginEmailKey(email), l.email},
+		{loginIPKey(r), l.ip},
+	} {
+		if k.policy.Threshold <= 0 {
+			continue
+		}
+		failures, err := l.attempts.RecordLoginFailure(r.Context(), k.key, now, now.Add(-LoginFailureWindow))
+		if err == nil {
+			if delay := k.policy.Delay(failures); delay > 0 {
+				err = l.attempts.LockLogin(r.Context(), k.key, now.Add(delay))
+			}
+		}
+		if err != nil {
+			log.Printf("[%s] failed to record login failure: %v", RequestIDFromContext(r.Context()), err)
+		}
+	}
+}
+
+// succeed forgets the email's failures. The IP's are kept, or an attacker could
+// reset them by signing in to their own account between guesses.
+func (l *LoginLimiter) succeed(r *http.Request, email string) {
+	if err := l.Unlock(r.Context(), email); err != nil {
+		log.Printf("[%s] failed to clear login failures: %v", RequestIDFromContext(r.Context()), err)
+	}
+}
+
+func loginEmailKey(email string) string {
+	return "email:" + strings.ToLower(email)
+}
+
+func loginIPKey(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+// dummyPasswordHash is compared against when the email is unknown, so a failed
+// login takes as long whether or not the account exists
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := models.HashPassword("not-a-real-password")
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
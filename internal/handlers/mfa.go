@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"transaction-logger/internal/auth"
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+)
+
+// totpIssuer names the account in authenticator apps
+const totpIssuer = "Transaction Logger"
+
+// MFAHandler manages TOTP two-factor authentication and completes two-step
+// logins. Enrollment endpoints require a signed-in user; API keys are refused.
+type MFAHandler struct {
+	users   store.UserStore
+	tokens  store.TokenStore
+	mfa     store.MFAStore
+	limiter *LoginLimiter
+	auditor *Auditor
+}
+
+// NewMFAHandler returns an MFAHandler. Wrong codes at login count as failed logins
+// for limiter, which may be nil.
+func NewMFAHandler(users store.UserStore, tokens store.TokenStore, mfa store.MFAStore, limiter *LoginLimiter, auditor *Auditor) *MFAHandler {
+	return &MFAHandler{users: users, tokens: tokens, mfa: mfa, limiter: limiter, auditor: auditor}
+}
+
+// MFAStatusResponse describes the caller's two-factor setup
+type MFAStatusResponse struct {
+	TOTPEnabled            bool       `json:"totp_enabled"`
+	TOTPEnabledAt          *time.Time `json:"totp_enabled_at,omitempty"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
+// RecoveryCodesResponse returns freshly issued recovery codes, the only time they are shown
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// Status reports whether the caller has TOTP enabled and how many recovery codes are left
+func (h *MFAHandler) Status(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	remaining, err := h.mfa.CountRecoveryCodes(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MFAStatusResponse{
+		TOTPEnabled:            user.TOTPEnabledAt != nil,
+		TOTPEnabledAt:          user.TOTPEnabledAt,
+		RecoveryCodesRemaining: remaining,
+	})
+}
+
+// EnrollTOTP starts enrollment with a new secret. TOTP is not required at login
+// until ConfirmTOTP succeeds; enrolling again before then replaces the secret.
+func (h *MFAHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	err = h.mfa.SetTOTPSecret(r.Context(), user.ID, secret)
+	if err == store.ErrConflict {
+		writeError(w, r, conflict(CodeConflict, "two-factor authentication is already enabled"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	h.auditor.record(r, models.NewAuditEvent(models.AuditTOTPEnroll, models.AuditTargetUser, user.ID))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(totpIssuer, user.Email, secret),
+	})
+}
+
+// ConfirmTOTP enables TOTP once the user proves their app produces valid codes,
+// and returns the first set of recovery codes
+func (h *MFAHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	if fields := validateRequest(req); len(fields) > 0 {
+		writeError(w, r, validationError(fields))
+		return
+	}
+
+	if user.TOTPEnabledAt != nil {
+		writeError(w, r, conflict(CodeConflict, "two-factor authentication is already enabled"))
+		return
+	}
+	if user.TOTPSecret == "" {
+		writeError(w, r, conflict(CodeConflict, "start enrollment before confirming it"))
+		return
+	}
+	step, valid := auth.ValidateTOTP(user.TOTPSecret, req.Code, time.Now())
+	if !valid {
+		writeError(w, r, badRequest("invalid code"))
+		return
+	}
+
+	codes, hashes, err := models.NewRecoveryCodes()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	err = h.mfa.EnableTOTP(r.Context(), user.ID, time.Now(), hashes)
+	if err == store.ErrConflict {
+		writeError(w, r, conflict(CodeConflict, "two-factor authentication is already enabled"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	// The confirming code may not be replayed at login
+	if err := h.mfa.UseTOTPStep(r.Context(), user.ID, step); err != nil && err != store.ErrConflict {
+		writeError(w, r, err)
+		return
+	}
+	h.auditor.record(r, models.NewAuditEvent(models.AuditTOTPEnable, models.AuditTargetUser, user.ID))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTP turns two-factor authentication off. The caller must give their
+// password and a current code or a recovery code.
+func (h *MFAHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.DisableMFARequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	if fields := validateRequest(req); len(fields) > 0 {
+		writeError(w, r, validationError(fields))
+		return
+	}
+
+	if user.TOTPEnabledAt == nil {
+		writeError(w, r, conflict(CodeConflict, "two-factor authentication is not enabled"))
+		return
+	}
+	if err := models.CheckPassword(user.Password, req.Password); err != nil {
+		writeError(w, r, forbidden("incorrect password or code"))
+		return
+	}
+	valid, err := h.checkCode(r, user, req.Code, true)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !valid {
+		writeError(w, r, forbidden("incorrect password or code"))
+		return
+	}
+
+	if err := h.mfa.DisableTOTP(r.Context(), user.ID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.auditor.record(r, models.NewAuditEvent(models.AuditTOTPDisable, models.AuditTargetUser, user.ID))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes. A current code
+// from the authenticator app is required.
+func (h *MFAHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	if fields := validateRequest(req); len(fields) > 0 {
+		writeError(w, r, validationError(fields))
+		return
+	}
+
+	if user.TOTPEnabledAt == nil {
+		writeError(w, r, conflict(CodeConflict, "two-factor authentication is not enabled"))
+		return
+	}
+	valid, err := h.checkCode(r, user, req.Code, false)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !valid {
+		writeError(w, r, forbidden("incorrect code"))
+		return
+	}
+
+	codes, hashes, err := models.NewRecoveryCodes()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := h.mfa.ReplaceRecoveryCodes(r.Context(), user.ID, hashes); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.auditor.record(r, models.NewAuditEvent(models.AuditRecoveryCodesReset, models.AuditTargetUser, user.ID))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Verify completes a two-step login, exchanging the challenge from Login and a
+// TOTP or recovery code for tokens. Each challenge works once.
+func (h *MFAHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	var req models.MFAVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	if fields := validateRequest(req); len(fields) > 0 {
+		writeError(w, r, validationError(fields))
+		return
+	}
+
+	claims, err := auth.ValidateMFAChallenge(req.MFAToken)
+	if err != nil {
+		writeError(w, r, unauthorized("invalid or expired MFA token"))
+		return
+	}
+
+	user, err := h.users.GetUserByID(r.Context(), claims.UserID)
+	if err == store.ErrNotFound {
+		writeError(w, r, unauthorized("invalid or expired MFA token"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if user.DisabledAt != nil {
+		writeError(w, r, forbidden("account is disabled"))
+		return
+	}
+	// TOTP was disabled since the challenge was issued, e.g. by an admin
+	if user.TOTPEnabledAt == nil {
+		writeError(w, r, unauthorized("invalid or expired MFA token"))
+		return
+	}
+	if err := h.limiter.check(r, user.Email); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	valid, err := h.checkCode(r, user, req.Code, true)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !valid {
+		h.limiter.fail(r, user.Email)
+		h.auditor.record(r, models.NewAuditEvent(models.AuditMFAFailed, models.AuditTargetUser, user.ID))
+		writeError(w, r, unauthorized("invalid code"))
+		return
+	}
+
+	if err := h.tokens.RevokeAccessToken(r.Context(), claims.ID, claims.ExpiresAt.Time); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	resp, err := issueTokens(r, h.tokens, user)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.limiter.succeed(r, user.Email)
+	h.auditor.record(r, models.NewAuditEvent(models.AuditLogin, models.AuditTargetUser, user.ID).
+		WithActor(user.ID).WithMetadata("mfa", true))
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// checkCode reports whether code is an unused TOTP code for the user or, when
+// allowRecovery is set, one of their recovery codes, which is then used up
+func (h *MFAHandler) checkCode(r *http.Request, user *models.User, code string, allowRecovery bool) (bool, error) {
+	if step, ok := auth.ValidateTOTP(user.TOTPSecret, code, time.Now()); ok {
+		err := h.mfa.UseTOTPStep(r.Context(), user.ID, step)
+		if err == store.ErrConflict {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	if !allowRecovery {
+		return false, nil
+	}
+
+	err := h.mfa.ConsumeRecoveryCode(r.Context(), user.ID, models.HashRecoveryCode(code), time.Now())
+	if err == store.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// sessionUser loads the signed-in caller
+func (h *MFAHandler) sessionUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userID, ok := requireSession(w, r)
+	if !ok {
+		return nil, false
+	}
+	user, err := h.users.GetUserByID(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,423 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"transaction-logger/internal/auth"
+	"transaction-logger/internal/mail"
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+)
+
+// OrganizationHeader selects the organization a request acts in. Routes mounted under
+// /api/organizations/{org_id} take it from the path instead; without either, the
+// caller's default organization is used.
+const OrganizationHeader = "X-Organization-ID"
+
+// invitationTTL is how long an invitation can be accepted for
+const invitationTTL = 7 * 24 * time.Hour
+
+// OrganizationMiddleware resolves the active organization for the transaction and
+// account routes and checks that the caller is a member. Non-members get 404 so
+// organization IDs cannot be probed, and viewers may only read.
+func OrganizationMiddleware(users store.UserStore, orgs store.OrganizationStore) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := requireUser(w, r)
+			if !ok {
+				return
+			}
+
+			orgID := mux.Vars(r)["org_id"]
+			if orgID == "" {
+				orgID = r.Header.Get(OrganizationHeader)
+			}
+			if orgID == "" {
+				user, err := users.GetUserByID(r.Context(), userID)
+				if err != nil {
+					writeError(w, r, err)
+					return
+				}
+				orgID = user.DefaultOrganizationID
+			}
+			if orgID == "" {
+				writeError(w, r, badRequest(OrganizationHeader+" header is required"))
+				return
+			}
+
+			membership, err := orgs.GetMembership(r.Context(), orgID, userID)
+			if err == store.ErrNotFound {
+				writeError(w, r, notFound("organization not found"))
+				return
+			}
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+
+			if !models.OrgRoleCanWrite(membership.Role) && r.Method != http.MethodGet && r.Method != http.MethodHead {
+				writeError(w, r, forbidden("viewers cannot make changes in this organization"))
+				return
+			}
+
+			ctx := auth.WithOrganization(r.Context(), auth.Organization{ID: membership.OrganizationID, Role: membership.Role})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// requireOrganization returns the organization set by OrganizationMiddleware,
+// writing a 401 if the request is not authenticated at all
+func requireOrganization(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if _, ok := requireUser(w, r); !ok {
+		return "", false
+	}
+	org, ok := auth.OrganizationFromContext(r.Context())
+	if !ok {
+		writeError(w, r, badRequest("no active organization"))
+	}
+	return org.ID, ok
+}
+
+// OrganizationHandler manages organizations, their members and invitations.
+// Every endpoint requires a signed-in user; API keys are refused.
+type OrganizationHandler struct {
+	orgs    store.OrganizationStore
+	users   store.UserStore
+	mailer  mail.Mailer
+	links   mail.Links
+	auditor *Auditor
+}
+
+// NewOrganizationHandler returns an OrganizationHandler that emails invitation
+// tokens through mailer, which may be nil to send nothing
+func NewOrganizationHandler(orgs store.OrganizationStore, users store.UserStore, mailer mail.Mailer, links mail.Links, auditor *Auditor) *OrganizationHandler {
+	return &OrganizationHandler{orgs: orgs, users: users, mailer: mailer, links: links, auditor: auditor}
+}
+
+// ListOrganizations returns the organizations the caller belongs to
+func (h *OrganizationHandler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	memberships, err := h.orgs.ListMemberships(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": memberships,
+	})
+}
+
+// CreateOrganization creates an organization owned by the caller
+func (h *OrganizationHandler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.CreateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	if fields := validateRequest(req); len(fields) > 0 {
+		writeError(w, r, validationError(fields))
+		return
+	}
+
+	org := models.NewOrganization(req.Name)
+	owner := &models.Membership{OrganizationID: org.ID, UserID: userID, Role: models.OrgRoleOwner, CreatedAt: org.CreatedAt}
+	if err := h.orgs.CreateOrganization(r.Context(), org, owner); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.recordIn(r, models.NewAuditEvent(models.AuditOrganizationCreate, models.AuditTargetOrganization, org.ID).
+		WithDiff(nil, org), org.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(org)
+}
+
+// ListMembers returns the members of an organization the caller belongs to
+func (h *OrganizationHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	caller, ok := h.membership(w, r, false)
+	if !ok {
+		return
+	}
+
+	members, err := h.orgs.ListMembers(r.Context(), caller.OrganizationID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": members,
+	})
+}
+
+// UpdateMember changes a member's role. The owner's role cannot be changed and
+// nobody can be made owner.
+func (h *OrganizationHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	caller, ok := h.membership(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	if fields := validateRequest(req); len(fields) > 0 {
+		writeError(w, r, validationError(fields))
+		return
+	}
+
+	member, ok := h.member(w, r, caller.OrganizationID)
+	if !ok {
+		return
+	}
+	if member.Role == models.OrgRoleOwner {
+		writeError(w, r, conflict(CodeConflict, "the owner's role cannot be changed"))
+		return
+	}
+
+	if err := h.orgs.SetMemberRole(r.Context(), member.OrganizationID, member.UserID, req.Role); err != nil {
+		h.writeMemberError(w, r, err)
+		return
+	}
+	h.recordIn(r, models.NewAuditEvent(models.AuditMemberUpdate, models.AuditTargetUser, member.UserID).
+		WithChange("role", member.Role, req.Role), member.OrganizationID)
+	member.Role = req.Role
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(member)
+}
+
+// RemoveMember removes a member from the organization. Admins and the owner may
+// remove anyone but the owner; other members may only remove themselves.
+func (h *OrganizationHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	caller, ok := h.membership(w, r, false)
+	if !ok {
+		return
+	}
+
+	member, ok := h.member(w, r, caller.OrganizationID)
+	if !ok {
+		return
+	}
+	if member.UserID != caller.UserID && !models.OrgRoleCanManage(caller.Role) {
+		writeError(w, r, forbidden("only organization admins can remove other members"))
+		return
+	}
+	if member.Role == models.OrgRoleOwner {
+		writeError(w, r, conflict(CodeConflict, "the owner cannot be removed"))
+		return
+	}
+
+	if err := h.orgs.RemoveMember(r.Context(), member.OrganizationID, member.UserID); err != nil {
+		h.writeMemberError(w, r, err)
+		return
+	}
+	h.recordIn(r, models.NewAuditEvent(models.AuditMemberRemove, models.AuditTargetUser, member.UserID).
+		WithChange("role", member.Role, nil), member.OrganizationID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateInvitation invites an email address to join the organization. The token
+// is only ever sent to that address, never returned to the inviter.
+func (h *OrganizationHandler) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	caller, ok := h.membership(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req models.CreateInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	if fields := validateRequest(req); len(fields) > 0 {
+		writeError(w, r, validationError(fields))
+		return
+	}
+
+	invitation, token, err := models.NewInvitation(caller.OrganizationID, req.Email, req.Role, caller.UserID, invitationTTL)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := h.orgs.CreateInvitation(r.Context(), invitation); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	msg := h.links.Invitation(invitation.Email, caller.OrganizationName, invitation.Role, token)
+	if err := sendMail(r.Context(), h.mailer, msg); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.recordIn(r, models.NewAuditEvent(models.AuditInvitationCreate, models.AuditTargetInvitation, invitation.ID).
+		WithDiff(nil, invitation), invitation.OrganizationID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invitation)
+}
+
+// ListInvitations returns the organization's invitations that have not been accepted
+func (h *OrganizationHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	caller, ok := h.membership(w, r, true)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.orgs.ListInvitations(r.Context(), caller.OrganizationID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": invitations,
+	})
+}
+
+// AcceptInvitation adds the caller to the organization named by an invitation
+// token. The caller must be signed in with the invited email address, and must
+// have verified it.
+func (h *OrganizationHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.AcceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	if fields := validateRequest(req); len(fields) > 0 {
+		writeError(w, r, validationError(fields))
+		return
+	}
+
+	invitation, err := h.orgs.GetInvitationByHash(r.Context(), models.HashToken(req.Token))
+	if err == store.ErrNotFound || (err == nil && !invitation.Usable(time.Now())) {
+		writeError(w, r, notFound("invitation not found or expired"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	user, err := h.users.GetUserByID(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		writeError(w, r, forbidden("invitation was sent to a different email address"))
+		return
+	}
+	if user.EmailVerifiedAt == nil {
+		writeError(w, r, forbidden("verify your email address before accepting invitations"))
+		return
+	}
+
+	member := &models.Membership{
+		OrganizationID: invitation.OrganizationID,
+		UserID:         userID,
+		Role:           invitation.Role,
+		CreatedAt:      time.Now(),
+	}
+	err = h.orgs.AcceptInvitation(r.Context(), invitation.ID, member)
+	if err == store.ErrConflict {
+		writeError(w, r, notFound("invitation not found or expired"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.recordIn(r, models.NewAuditEvent(models.AuditInvitationAccept, models.AuditTargetInvitation, invitation.ID).
+		WithChange("role", nil, member.Role), member.OrganizationID)
+
+	membership, err := h.orgs.GetMembership(r.Context(), member.OrganizationID, userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(membership)
+}
+
+// membership returns the caller's membership of the organization in the {id} path
+// variable. Non-members get 404; when manage is set, members who are neither admin
+// nor owner get 403.
+func (h *OrganizationHandler) membership(w http.ResponseWriter, r *http.Request, manage bool) (*models.Membership, bool) {
+	userID, ok := requireSession(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	m, err := h.orgs.GetMembership(r.Context(), mux.Vars(r)["id"], userID)
+	if err == store.ErrNotFound {
+		writeError(w, r, notFound("organization not found"))
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return nil, false
+	}
+	if manage && !models.OrgRoleCanManage(m.Role) {
+		writeError(w, r, forbidden("only organization admins can manage members and invitations"))
+		return nil, false
+	}
+	return m, true
+}
+
+// member returns the membership named by the {user_id} path variable
+func (h *OrganizationHandler) member(w http.ResponseWriter, r *http.Request, orgID string) (*models.Membership, bool) {
+	m, err := h.orgs.GetMembership(r.Context(), orgID, mux.Vars(r)["user_id"])
+	if err != nil {
+		h.writeMemberError(w, r, err)
+		return nil, false
+	}
+	return m, true
+}
+
+// recordIn audits e as happening in the organization, which these routes take
+// from the path or the request rather than from OrganizationMiddleware
+func (h *OrganizationHandler) recordIn(r *http.Request, e *models.AuditEvent, orgID string) {
+	e.OrganizationID = orgID
+	h.auditor.record(r, e)
+}
+
+func (h *OrganizationHandler) writeMemberError(w http.ResponseWriter, r *http.Request, err error) {
+	if err == store.ErrNotFound {
+		err = notFound("member not found")
+	}
+	writeError(w, r, err)
+}
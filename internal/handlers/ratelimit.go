@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"transaction-logger/internal/auth"
+	"transaction-logger/internal/ratelimit"
+	"transaction-logger/internal/store"
+)
+
+// RateLimit is middleware giving each caller a token bucket of limit for the
+// routes it wraps. Callers are told apart by API key, then user, then client IP,
+// so it must run after AuthMiddleware to see the first two. name keeps the buckets
+// of differently limited routes apart. Responses carry RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers; rejected requests get a 429
+// with Retry-After. A disabled limit adds nothing.
+//
+// If the store fails the request is let through: an outage of the limiter should
+// not take the API down with it.
+func RateLimit(limits store.RateLimitStore, name string, limit ratelimit.Limit) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if !limit.Enabled() {
+			return next
+		}
+		policy := strconv.Itoa(limit.Requests) + ";w=" + strconv.Itoa(int(limit.Per.Seconds()))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := limits.TakeRateLimitToken(r.Context(), name+":"+rateLimitCaller(r), limit, time.Now())
+			if err != nil {
+				log.Printf("rate limit %s: %v", name, err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Policy", policy)
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(retryAfterSeconds(res.Reset)))
+			if !res.Allowed {
+				writeError(w, r, tooManyRequests("rate limit exceeded, try again later", res.RetryAfter))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitCaller identifies whose bucket a request draws from
+func rateLimitCaller(r *http.Request) string {
+	if claims, ok := auth.ClaimsFromContext(r.Context()); ok {
+		if claims.APIKeyID != "" {
+			return "key:" + claims.APIKeyID
+		}
+		if claims.UserID != "" {
+			return "user:" + claims.UserID
+		}
+	}
+	return "ip:" + clientIP(r)
+}
@@ -1,22 +1,29 @@
 package handlers
 
 import (
-	"database/sql"
+	crand "crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"io"
 	"math/rand"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/gorilla/mux"
+
 	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
 )
 
 type TransactionHandler struct {
-	db *sql.DB
+	store   store.TransactionStore
+	auditor *Auditor
 }
 
-func NewTransactionHandler(db *sql.DB) *TransactionHandler {
-	return &TransactionHandler{db: db}
+func NewTransactionHandler(store store.TransactionStore, auditor *Auditor) *TransactionHandler {
+	return &TransactionHandler{store: store, auditor: auditor}
 }
 
 // GetTransactionsResponse represents the paginated response for transactions
@@ -32,10 +39,37 @@ type GetTransactionsResponse struct {
 	} `json:"pagination"`
 }
 
+// CursorTransactionsResponse represents a page of transactions fetched with a cursor
+type CursorTransactionsResponse struct {
+	Data       []models.Transaction `json:"data"`
+	Pagination struct {
+		Count      int    `json:"count"`
+		PerPage    int    `json:"per_page"`
+		NextCursor string `json:"next_cursor,omitempty"`
+		PrevCursor string `json:"prev_cursor,omitempty"`
+		HasMore    bool   `json:"has_more"`
+	} `json:"pagination"`
+}
+
 func (h *TransactionHandler) GetTransactions(w http.ResponseWriter, r *http.Request) {
-	// Get user ID from context (set by AuthMiddleware)
-	userID := r.Context().Value("userID").(string)
+	// Get the active organization (set by OrganizationMiddleware)
+	orgID, ok := requireOrganization(w, r)
+	if !ok {
+		return
+	}
+
+	h.listTransactions(w, r, orgID)
+}
+
+// GetOrganizationTransactions lists the transactions of the organization named in
+// the path, for staff holding auth.PermReadAnyTransactions. It accepts the same
+// parameters as GetTransactions and never modifies anything.
+func (h *TransactionHandler) GetOrganizationTransactions(w http.ResponseWriter, r *http.Request) {
+	h.listTransactions(w, r, mux.Vars(r)["id"])
+}
 
+// listTransactions writes one page of the organization's transactions
+func (h *TransactionHandler) listTransactions(w http.ResponseWriter, r *http.Request, orgID string) {
 	// Parse query parameters with defaults
 	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
 	if page < 1 {
@@ -50,14 +84,22 @@ func (h *TransactionHandler) GetTransactions(w http.ResponseWriter, r *http.Requ
 	}
 	offset := (page - 1) * pageSize
 
-	// Get total count of transactions for this user
-	var total int
-	err := h.db.QueryRow(
-		`SELECT COUNT(*) FROM transactions WHERE user_id = $1`,
-		userID,
-	).Scan(&total)
+	filter, err := models.ParseTransactionFilter(r.URL.Query())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	// A cursor parameter (empty for the first page) switches to keyset pagination
+	if r.URL.Query().Has("cursor") {
+		h.getTransactionsByCursor(w, r, orgID, filter, pageSize, r.URL.Query().Get("cursor"))
+		return
+	}
+
+	// Get the page and the total count of matching transactions for this user
+	transactions, total, err := h.store.ListTransactions(r.Context(), orgID, filter, pageSize, offset)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
@@ -67,43 +109,6 @@ func (h *TransactionHandler) GetTransactions(w http.ResponseWriter, r *http.Requ
 		totalPages++
 	}
 
-	// Get paginated transactions
-	rows, err := h.db.Query(
-		`SELECT id, timestamp, sender_account, receiver_account, 
-		amount, currency, transaction_type, status, user_id 
-		FROM transactions WHERE user_id = $1
-		ORDER BY timestamp DESC
-		LIMIT $2 OFFSET $3`,
-		userID,
-		pageSize,
-		offset,
-	)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer rows.Close()
-
-	var transactions []models.Transaction
-	for rows.Next() {
-		var t models.Transaction
-		if err := rows.Scan(
-			&t.ID,
-			&t.Timestamp,
-			&t.SenderAccount,
-			&t.ReceiverAccount,
-			&t.Amount,
-			&t.Currency,
-			&t.TransactionType,
-			&t.Status,
-			&t.UserID,
-		); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		transactions = append(transactions, t)
-	}
-
 	// Prepare response
 	response := GetTransactionsResponse{
 		Data: transactions,
@@ -128,16 +133,83 @@ func (h *TransactionHandler) GetTransactions(w http.ResponseWriter, r *http.Requ
 	json.NewEncoder(w).Encode(response)
 }
 
+// getTransactionsByCursor writes one page of transactions using keyset pagination on (timestamp, id)
+func (h *TransactionHandler) getTransactionsByCursor(w http.ResponseWriter, r *http.Request, orgID string, filter models.TransactionFilter, pageSize int, rawCursor string) {
+	if filter.SortColumn != "timestamp" {
+		writeError(w, r, badRequest("cursor pagination only supports sorting by timestamp"))
+		return
+	}
+
+	var cursor *models.TransactionCursor
+	if rawCursor != "" {
+		var err error
+		if cursor, err = models.DecodeTransactionCursor(rawCursor); err != nil {
+			writeError(w, r, badRequest(err.Error()))
+			return
+		}
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	transactions, err := h.store.ListTransactionsByCursor(r.Context(), orgID, filter, cursor, pageSize+1)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	extra := len(transactions) > pageSize
+	if extra {
+		transactions = transactions[:pageSize]
+	}
+
+	hasNext, hasPrev := extra, cursor != nil
+	if !cursor.Forward() {
+		for i, j := 0, len(transactions)-1; i < j; i, j = i+1, j-1 {
+			transactions[i], transactions[j] = transactions[j], transactions[i]
+		}
+		hasNext, hasPrev = true, extra
+	}
+
+	response := CursorTransactionsResponse{Data: transactions}
+	response.Pagination.Count = len(transactions)
+	response.Pagination.PerPage = pageSize
+	response.Pagination.HasMore = hasNext
+	if len(transactions) > 0 {
+		if hasNext {
+			response.Pagination.NextCursor = models.NewTransactionCursor(transactions[len(transactions)-1], models.CursorNext).Encode()
+		}
+		if hasPrev {
+			response.Pagination.PrevCursor = models.NewTransactionCursor(transactions[0], models.CursorPrev).Encode()
+		}
+	}
 
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
 
-// CreateTransaction handles the creation of a single transaction
+// CreateTransaction handles the creation of a single transaction.
+// Requests carrying an Idempotency-Key header are recorded so that retries
+// with the same body replay the original response instead of creating a new row.
 func (h *TransactionHandler) CreateTransaction(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context (set by AuthMiddleware)
-	userID := r.Context().Value("userID").(string)
+	userID, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	// Get the active organization (set by OrganizationMiddleware)
+	orgID, ok := requireOrganization(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
 
 	var req models.CreateTransactionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, r, badRequest(err.Error()))
 		return
 	}
 
@@ -145,8 +217,15 @@ func (h *TransactionHandler) CreateTransaction(w http.ResponseWriter, r *http.Re
 	req.UserID = userID
 
 	// Validate request
-	if req.SenderAccount == "" || req.ReceiverAccount == "" || req.Amount <= 0 {
-		http.Error(w, "Invalid transaction data", http.StatusBadRequest)
+	amount, fields := requestAmount(req, validateRequest(req))
+	if len(fields) > 0 {
+		writeError(w, r, validationError(fields))
+		return
+	}
+
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if len(idempotencyKey) > 255 {
+		writeError(w, r, badRequest("Idempotency-Key must be at most 255 characters"))
 		return
 	}
 
@@ -155,93 +234,346 @@ func (h *TransactionHandler) CreateTransaction(w http.ResponseWriter, r *http.Re
 		Timestamp:       time.Now(),
 		SenderAccount:   req.SenderAccount,
 		ReceiverAccount: req.ReceiverAccount,
-		Amount:          req.Amount,
+		Amount:          amount,
 		Currency:        req.Currency,
 		TransactionType: req.TransactionType,
-		Status:          "Completed",
+		Status:          models.StatusPending, // Later statuses are reached through the status endpoints
+		OrganizationID:  orgID,
 		UserID:          userID,
 	}
 
-	_, err := h.db.Exec(
-		`INSERT INTO transactions 
-		(id, timestamp, sender_account, receiver_account, amount, currency, transaction_type, status, user_id) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
-		tx.ID, tx.Timestamp, tx.SenderAccount, tx.ReceiverAccount, tx.Amount, tx.Currency, tx.TransactionType, tx.Status, tx.UserID,
-	)
+	// The store saves the response with the key so a retry can replay it exactly
+	var idem *models.IdempotencyKey
+	if idempotencyKey != "" {
+		idem = &models.IdempotencyKey{
+			UserID:       userID,
+			Key:          idempotencyKey,
+			RequestHash:  models.RequestFingerprint(r.Method, r.URL.Path, orgID, body),
+			StatusCode:   http.StatusCreated,
+			CreatedAt:    time.Now(),
+		}
+	}
 
+	existing, err := h.store.CreateTransaction(r.Context(), &tx, idem)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+	if existing != nil {
+		if existing.RequestHash != idem.RequestHash {
+			writeError(w, r, newAPIError(http.StatusUnprocessableEntity, CodeIdempotencyMismatch, "Idempotency-Key was already used with a different request"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(existing.StatusCode)
+		w.Write(existing.ResponseBody)
+		return
+	}
+	h.auditor.record(r, models.NewAuditEvent(models.AuditTransactionCreate, models.AuditTargetTransaction, tx.ID).WithDiff(nil, tx))
 
+	// Encoded the same way as the stored response, so a replay is byte for byte identical
+	response, err := json.Marshal(tx)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(tx)
+	w.Write(response)
+}
+
+// requestAmount parses req's amount for its currency, adding a field error to
+// fields if it is malformed or not positive. A missing amount is left to validation.
+func requestAmount(req models.CreateTransactionRequest, fields []FieldError) (models.Money, []FieldError) {
+	if req.Amount == "" {
+		return 0, fields
+	}
+	amount, err := models.ParseAmount(req.Amount.String(), req.Currency)
+	if err != nil {
+		return 0, append(fields, FieldError{Field: "amount", Message: err.Error()})
+	}
+	if !amount.IsPositive() {
+		return 0, append(fields, FieldError{Field: "amount", Message: "must be greater than 0"})
+	}
+	return amount, fields
+}
+
+// samplePaths are drawn from for sample transactions, weighted towards Completed.
+// Each is created Pending, like every transaction, and then moved through the rest,
+// so the history of every sample is one a real transaction could have.
+var samplePaths = [][]string{
+	{models.StatusPending, models.StatusCompleted},
+	{models.StatusPending, models.StatusCompleted},
+	{models.StatusPending, models.StatusAuthorized, models.StatusCompleted},
+	{models.StatusPending},
+	{models.StatusPending, models.StatusAuthorized},
+	{models.StatusPending, models.StatusFailed},
+	{models.StatusPending, models.StatusCompleted, models.StatusReversed},
+	{models.StatusPending, models.StatusAuthorized, models.StatusCancelled},
 }
 
 // GenerateSampleTransactions generates sample transactions for testing
 func (h *TransactionHandler) GenerateSampleTransactions(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context (set by AuthMiddleware)
-	userID := r.Context().Value("userID").(string)
+	userID, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	// Get the active organization (set by OrganizationMiddleware)
+	orgID, ok := requireOrganization(w, r)
+	if !ok {
+		return
+	}
+
+	// Generate 100 sample transactions
+	samples := make([]models.Transaction, 100)
+	paths := make([][]string, len(samples))
+	for i := range samples {
+		paths[i] = samplePaths[rand.Intn(len(samplePaths))]
+		samples[i] = models.Transaction{
+			ID:              generateID(),
+			Timestamp:       time.Now().Add(-time.Duration(rand.Intn(365)) * 24 * time.Hour),
+			SenderAccount:   generateAccountNumber(),
+			ReceiverAccount: generateAccountNumber(),
+			Amount:          models.Money(rand.Int63n(1000000) + 1),
+			Currency:        []string{"USD", "EUR", "GBP"}[rand.Intn(3)],
+			TransactionType: []string{"Transfer", "Deposit", "Withdrawal"}[rand.Intn(3)],
+			Status:          paths[i][0],
+			OrganizationID:  orgID,
+			UserID:          userID, // Include the user ID in the transaction
+		}
+	}
 
-	tx, err := h.db.Begin()
+	batch, err := h.store.BeginImport(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
-	defer tx.Rollback()
+	defer batch.Rollback()
 
-	// Prepare the statement with user_id parameter
-	stmt, err := tx.Prepare(`
-		INSERT INTO transactions (
-			id, timestamp, sender_account, receiver_account, 
-			amount, currency, transaction_type, status, user_id
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-	`)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := batch.Insert(r.Context(), samples); err != nil {
+		writeError(w, r, err)
 		return
 	}
-	defer stmt.Close()
 
-	// Generate 100 sample transactions
-	for i := 0; i < 100; i++ {
-		timestamp := time.Now().Add(-time.Duration(rand.Intn(365)) * 24 * time.Hour)
-		sender := generateAccountNumber()
-		receiver := generateAccountNumber()
-		amount := float64(rand.Intn(10000)) + rand.Float64()
-		currency := []string{"USD", "EUR", "GBP"}[rand.Intn(3)]
-		txType := []string{"Transfer", "Deposit", "Withdrawal"}[rand.Intn(3)]
-
-		_, err = stmt.Exec(
-			generateID(),
-			timestamp,
-			sender,
-			receiver,
-			amount,
-			currency,
-			txType,
-			"Completed",
-			userID, // Include the user ID in the transaction
-		)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	// Later statuses are reached the way the status endpoints reach them, recording
+	// history, within the batch so a failure leaves no samples behind
+	for i, t := range samples {
+		for j := 1; j < len(paths[i]); j++ {
+			err := batch.Transition(r.Context(), models.StatusChange{
+				TransactionID:  t.ID,
+				OrganizationID: orgID,
+				UserID:         userID,
+				From:           paths[i][j-1],
+				To:             paths[i][j],
+				At:             time.Now(),
+			})
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := batch.Commit(); err != nil {
+		writeError(w, r, err)
 		return
 	}
+	h.auditor.record(r, models.NewAuditEvent(models.AuditTransactionSample, "", "").WithMetadata("count", len(samples)))
 
 	w.WriteHeader(http.StatusCreated)
 	w.Write([]byte("Successfully generated 100 transactions"))
 }
 
+// GetTransaction returns a single transaction in the active organization
+func (h *TransactionHandler) GetTransaction(w http.ResponseWriter, r *http.Request) {
+	// Get the active organization (set by OrganizationMiddleware)
+	orgID, ok := requireOrganization(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := h.store.GetTransaction(r.Context(), orgID, mux.Vars(r)["id"])
+	if err == store.ErrNotFound {
+		writeError(w, r, notFound("transaction not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tx)
+}
+
+// UpdateTransaction changes the status of a transaction through an allowed transition
+func (h *TransactionHandler) UpdateTransaction(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context (set by AuthMiddleware)
+	userID, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	// Get the active organization (set by OrganizationMiddleware)
+	orgID, ok := requireOrganization(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateTransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	if fields := validateRequest(req); len(fields) > 0 {
+		writeError(w, r, validationError(fields))
+		return
+	}
+
+	h.transition(w, r, models.StatusChange{
+		TransactionID:  mux.Vars(r)["id"],
+		OrganizationID: orgID,
+		UserID:         userID,
+		To:             req.Status,
+		Reason:         req.Reason,
+	}, models.AuditTransactionStatus)
+}
+
+// AdvanceTransaction moves a transaction to the status named by the {action} path
+// variable, e.g. POST /transactions/{id}/complete
+func (h *TransactionHandler) AdvanceTransaction(w http.ResponseWriter, r *http.Request) {
+	status, ok := models.StatusForAction(mux.Vars(r)["action"])
+	if !ok {
+		writeError(w, r, notFound("unknown transaction action"))
+		return
+	}
+	h.advance(w, r, status, models.AuditTransactionStatus)
+}
+
+// VoidTransaction cancels a transaction that has not completed
+func (h *TransactionHandler) VoidTransaction(w http.ResponseWriter, r *http.Request) {
+	h.advance(w, r, models.StatusCancelled, models.AuditTransactionVoid)
+}
+
+// GetTransactionHistory returns a transaction's status changes, oldest first
+func (h *TransactionHandler) GetTransactionHistory(w http.ResponseWriter, r *http.Request) {
+	// Get the active organization (set by OrganizationMiddleware)
+	orgID, ok := requireOrganization(w, r)
+	if !ok {
+		return
+	}
+
+	history, err := h.store.ListStatusHistory(r.Context(), orgID, mux.Vars(r)["id"])
+	if err == store.ErrNotFound {
+		writeError(w, r, notFound("transaction not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": history,
+	})
+}
+
+// advance moves the transaction in the {id} path variable to status, taking an
+// optional reason from the request body
+func (h *TransactionHandler) advance(w http.ResponseWriter, r *http.Request, status, action string) {
+	// Get user ID from context (set by AuthMiddleware)
+	userID, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	// Get the active organization (set by OrganizationMiddleware)
+	orgID, ok := requireOrganization(w, r)
+	if !ok {
+		return
+	}
+
+	// The body is optional
+	var req models.TransitionRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, badRequest(err.Error()))
+			return
+		}
+	}
+
+	if fields := validateRequest(req); len(fields) > 0 {
+		writeError(w, r, validationError(fields))
+		return
+	}
+
+	h.transition(w, r, models.StatusChange{
+		TransactionID:  mux.Vars(r)["id"],
+		OrganizationID: orgID,
+		UserID:         userID,
+		To:             status,
+		Reason:         req.Reason,
+	}, action)
+}
+
+// transition applies change to one of the organization's transactions, filling in
+// its current status and the time, audits it as action and writes the updated transaction
+func (h *TransactionHandler) transition(w http.ResponseWriter, r *http.Request, change models.StatusChange, action string) {
+	tx, err := h.store.GetTransaction(r.Context(), change.OrganizationID, change.TransactionID)
+	if err == store.ErrNotFound {
+		writeError(w, r, notFound("transaction not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if !models.CanTransition(tx.Status, change.To) {
+		writeError(w, r, conflict(CodeInvalidTransition, invalidTransition(tx.Status, change.To)))
+		return
+	}
+
+	change.From = tx.Status
+	change.At = time.Now()
+	err = h.store.UpdateTransactionStatus(r.Context(), change)
+	if err == store.ErrConflict {
+		writeError(w, r, conflict(CodeConflict, "transaction was modified concurrently"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	e := models.NewAuditEvent(action, models.AuditTargetTransaction, tx.ID).WithChange("status", change.From, change.To)
+	if change.Reason != "" {
+		e.WithMetadata("reason", change.Reason)
+	}
+	h.auditor.record(r, e)
+	tx.Status = change.To
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tx)
+}
+
+// invalidTransition explains why a transaction cannot move from one status to another
+func invalidTransition(from, to string) string {
+	msg := "cannot change status from " + from + " to " + to
+	next := models.NextStatuses(from)
+	if len(next) == 0 {
+		return msg + "; " + from + " is final"
+	}
+	return msg + "; " + from + " can only change to " + strings.Join(next, ", ")
+}
+
+// generateID returns a new transaction ID. The random suffix keeps IDs unique
+// when many transactions are created within the same second, as in imports.
 func generateID() string {
-	rand.Seed(time.Now().UnixNano())
-	return "TXN" + time.Now().Format("20060102150405") + strconv.Itoa(rand.Intn(1000))
+	b := make([]byte, 8)
+	crand.Read(b)
+	return "TXN" + time.Now().Format("20060102150405") + hex.EncodeToString(b)
 }
 
 // generateAccountNumber generates a random 12-digit account number
@@ -0,0 +1,34 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// LogMailer stands in for a mail server during development. With Dir set each
+// message is written there as an .eml file; otherwise it is written to the log.
+type LogMailer struct {
+	From string
+	Dir  string
+
+	seq atomic.Int64
+}
+
+// Send records msg instead of delivering it
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	if m.Dir == "" {
+		log.Printf("Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+		return nil
+	}
+
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%d.eml", time.Now().Format("20060102T150405.000000000"), m.seq.Add(1))
+	return os.WriteFile(filepath.Join(m.Dir, name), format(m.From, msg), 0o644)
+}
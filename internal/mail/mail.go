@@ -0,0 +1,65 @@
+// Package mail sends the transactional emails the API needs: address
+// verification, password resets and organization invitations. Mailer is
+// implemented over SMTP for production and by LogMailer for local testing.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Links builds the URLs emailed to users. BaseURL is the web app the pages live
+// on; each page is expected to POST the token back to the matching API endpoint.
+type Links struct {
+	BaseURL string
+}
+
+// VerifyEmail returns the verification email for a token
+func (l Links) VerifyEmail(to, token string) Message {
+	return Message{
+		To:      to,
+		Subject: "Verify your email address",
+		Body: "Confirm this is your email address by opening the link below:\n\n" +
+			l.url("/verify-email", token) + "\n\n" +
+			"If you did not create an account, you can ignore this email.\n",
+	}
+}
+
+// ResetPassword returns the password reset email for a token
+func (l Links) ResetPassword(to, token string) Message {
+	return Message{
+		To:      to,
+		Subject: "Reset your password",
+		Body: "Choose a new password by opening the link below. It expires in one hour.\n\n" +
+			l.url("/reset-password", token) + "\n\n" +
+			"If you did not ask to reset your password, you can ignore this email.\n",
+	}
+}
+
+// Invitation returns the email inviting someone to join an organization
+func (l Links) Invitation(to, orgName, role, token string) Message {
+	return Message{
+		To:      to,
+		Subject: fmt.Sprintf("You have been invited to %s", orgName),
+		Body: fmt.Sprintf("You have been invited to join %s as %s. Accept the invitation by signing in with this email address and opening the link below:\n\n", orgName, role) +
+			l.url("/invitations/accept", token) + "\n",
+	}
+}
+
+func (l Links) url(path, token string) string {
+	return strings.TrimRight(l.BaseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
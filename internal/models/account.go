@@ -0,0 +1,78 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Account types
+const (
+	// AccountTypeAsset is an account created by a member of the organization
+	AccountTypeAsset = "asset"
+	// AccountTypeExternal is the per-organization, per-currency counterparty for
+	// money entering or leaving the organization's own accounts
+	AccountTypeExternal = "external"
+)
+
+// ErrAccountNotFound is returned when an account does not exist or belongs to another organization
+var ErrAccountNotFound = errors.New("account not found")
+
+type Account struct {
+	ID             string    `json:"id"`
+	OrganizationID string    `json:"organization_id"`
+	UserID         string    `json:"user_id"` // The member who created the account
+	Name           string    `json:"name"`
+	Currency       string    `json:"currency"`
+	Type           string    `json:"type"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type CreateAccountRequest struct {
+	Name     string `json:"name" validate:"required"`
+	Currency string `json:"currency" validate:"required,oneof=USD EUR GBP"`
+}
+
+// Balance is the sum of an account's ledger entries up to a point in time
+type Balance struct {
+	AccountID string    `json:"account_id"`
+	Currency  string    `json:"currency"`
+	Balance   Money     `json:"balance"`
+	AsOf      time.Time `json:"as_of"`
+}
+
+// NewAccount builds a new asset account in the organization, created by userID
+func NewAccount(orgID, userID, name, currency string) *Account {
+	return &Account{
+		ID:             "acc_" + time.Now().Format("20060102150405") + "_" + randomString(8),
+		OrganizationID: orgID,
+		UserID:         userID,
+		Name:           name,
+		Currency:       currency,
+		Type:           AccountTypeAsset,
+		CreatedAt:      time.Now(),
+	}
+}
+
+// NewExternalAccount builds the organization's external account for the currency,
+// created on demand by the first transaction userID posts in it. Stores keep only
+// one external account per organization and currency.
+func NewExternalAccount(orgID, userID, currency string) *Account {
+	return &Account{
+		ID:             "ext_" + orgID + "_" + currency,
+		OrganizationID: orgID,
+		UserID:         userID,
+		Name:           "External " + currency,
+		Currency:       currency,
+		Type:           AccountTypeExternal,
+		CreatedAt:      time.Now(),
+	}
+}
+
+// CheckCurrency returns ErrCurrencyMismatch if the account does not hold the currency
+func (a *Account) CheckCurrency(currency string) error {
+	if a.Currency != currency {
+		return fmt.Errorf("%w: account %s holds %s, not %s", ErrCurrencyMismatch, a.ID, a.Currency, currency)
+	}
+	return nil
+}
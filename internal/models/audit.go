@@ -0,0 +1,268 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Audit actions, named "<area>.<verb>". Filtering on just the area matches all of its actions.
+const (
+	AuditRegister               = "auth.register"
+	AuditLogin                  = "auth.login"
+	AuditLoginFailed            = "auth.login_failed"
+	AuditLoginChallenge         = "auth.mfa_challenge"
+	AuditMFAFailed              = "auth.mfa_failed"
+	AuditRefresh                = "auth.refresh"
+	AuditRefreshReuse           = "auth.refresh_reuse"
+	AuditLogout                 = "auth.logout"
+	AuditVerificationSent       = "auth.verification_sent"
+	AuditEmailVerified          = "auth.email_verified"
+	AuditPasswordResetRequested = "auth.password_reset_requested"
+	AuditPasswordReset          = "auth.password_reset"
+
+	AuditTOTPEnroll         = "mfa.totp_enroll"
+	AuditTOTPEnable         = "mfa.totp_enable"
+	AuditTOTPDisable        = "mfa.totp_disable"
+	AuditRecoveryCodesReset = "mfa.recovery_codes_regenerate"
+
+	AuditAPIKeyCreate = "api_key.create"
+	AuditAPIKeyRevoke = "api_key.revoke"
+
+	AuditTransactionCreate = "transaction.create"
+	AuditTransactionStatus = "transaction.update_status"
+	AuditTransactionVoid   = "transaction.void"
+	AuditTransactionImport = "transaction.import"
+	AuditTransactionSample = "transaction.generate_sample"
+
+	AuditAccountCreate = "account.create"
+
+	AuditOrganizationCreate = "organization.create"
+	AuditMemberUpdate       = "organization.member_update"
+	AuditMemberRemove       = "organization.member_remove"
+	AuditInvitationCreate   = "organization.invitation_create"
+	AuditInvitationAccept   = "organization.invitation_accept"
+
+	AuditUserRole     = "admin.user_role"
+	AuditUserDisable  = "admin.user_disable"
+	AuditUserEnable   = "admin.user_enable"
+	AuditUserUnlock   = "admin.user_unlock"
+	AuditUserMFAReset = "admin.user_mfa_reset"
+)
+
+// Audit target types
+const (
+	AuditTargetUser         = "user"
+	AuditTargetTransaction  = "transaction"
+	AuditTargetAccount      = "account"
+	AuditTargetOrganization = "organization"
+	AuditTargetInvitation   = "invitation"
+	AuditTargetAPIKey       = "api_key"
+)
+
+// AuditEvent records one action taken through the API. Events are append-only.
+type AuditEvent struct {
+	ID string `json:"id"`
+	// ActorID is the user who acted; empty when nobody was identified, such as a failed login
+	ActorID        string `json:"actor_id,omitempty"`
+	APIKeyID       string `json:"api_key_id,omitempty"`
+	OrganizationID string `json:"organization_id,omitempty"`
+	Action         string `json:"action"`
+	TargetType     string `json:"target_type,omitempty"`
+	TargetID       string `json:"target_id,omitempty"`
+	IP             string `json:"ip,omitempty"`
+	UserAgent      string `json:"user_agent,omitempty"`
+	RequestID      string `json:"request_id,omitempty"`
+	// Changes maps each changed field to its values before and after the action
+	Changes   map[string]AuditChange `json:"changes,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// AuditChange is a field's value before and after an action; nil when it did not exist
+type AuditChange struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// NewAuditEvent returns an event for action on a target
+func NewAuditEvent(action, targetType, targetID string) *AuditEvent {
+	now := time.Now().UTC()
+	return &AuditEvent{
+		ID:         "aud_" + now.Format("20060102150405") + "_" + randomString(8),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		CreatedAt:  now,
+	}
+}
+
+// WithActor sets who acted, for requests that are not yet authenticated
+func (e *AuditEvent) WithActor(userID string) *AuditEvent {
+	e.ActorID = userID
+	return e
+}
+
+// WithDiff records the fields that differ between the JSON forms of before and
+// after, either of which may be nil. Fields hidden from JSON are never recorded.
+func (e *AuditEvent) WithDiff(before, after interface{}) *AuditEvent {
+	b, a := jsonFields(before), jsonFields(after)
+	for field := range a {
+		if _, ok := b[field]; !ok {
+			b[field] = nil
+		}
+	}
+	for field, was := range b {
+		if now := a[field]; !bytes.Equal(was, now) {
+			e.WithChange(field, was, now)
+		}
+	}
+	return e
+}
+
+// WithChange records one field's values before and after the action
+func (e *AuditEvent) WithChange(field string, before, after interface{}) *AuditEvent {
+	if e.Changes == nil {
+		e.Changes = map[string]AuditChange{}
+	}
+	e.Changes[field] = AuditChange{Before: rawJSON(before), After: rawJSON(after)}
+	return e
+}
+
+// WithMetadata adds context that is not a change to the target, such as a failure reason
+func (e *AuditEvent) WithMetadata(key string, value interface{}) *AuditEvent {
+	if e.Metadata == nil {
+		e.Metadata = map[string]interface{}{}
+	}
+	e.Metadata[key] = value
+	return e
+}
+
+// jsonFields returns v's top-level JSON fields; none for nil or a non-object
+func jsonFields(v interface{}) map[string]json.RawMessage {
+	var fields map[string]json.RawMessage
+	if data, err := json.Marshal(v); err == nil {
+		json.Unmarshal(data, &fields)
+	}
+	if fields == nil {
+		fields = map[string]json.RawMessage{}
+	}
+	return fields
+}
+
+func rawJSON(v interface{}) json.RawMessage {
+	if raw, ok := v.(json.RawMessage); ok {
+		return raw
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// AuditFilter holds the optional predicates for listing audit events
+type AuditFilter struct {
+	// UserID matches events the user took or that targeted them
+	UserID         string
+	ActorID        string
+	OrganizationID string
+	// Action is a full action name, or an area such as "auth" matching all of its actions
+	Action     string
+	TargetType string
+	TargetID   string
+	From       *time.Time
+	To         *time.Time
+}
+
+// ParseAuditFilter builds a filter from query parameters. Dates accept RFC 3339 or YYYY-MM-DD.
+func ParseAuditFilter(q url.Values) (AuditFilter, error) {
+	f := AuditFilter{
+		UserID:         q.Get("user_id"),
+		ActorID:        q.Get("actor_id"),
+		OrganizationID: q.Get("organization_id"),
+		Action:         q.Get("action"),
+		TargetType:     q.Get("target_type"),
+		TargetID:       q.Get("target_id"),
+	}
+
+	var err error
+	if f.From, err = parseTimeParam(q, "from"); err != nil {
+		return f, err
+	}
+	if f.To, err = parseTimeParam(q, "to"); err != nil {
+		return f, err
+	}
+	if f.From != nil && f.To != nil && f.From.After(*f.To) {
+		return f, fmt.Errorf("from must not be after to")
+	}
+	return f, nil
+}
+
+// Where returns the SQL predicates for the filter, each prefixed with " AND ", to
+// be appended after a WHERE clause, along with their arguments. Placeholders are
+// numbered starting at argOffset+1.
+func (f AuditFilter) Where(argOffset int) (string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+
+	add := func(clause string, arg interface{}) {
+		args = append(args, arg)
+		clauses = append(clauses, strings.ReplaceAll(clause, "$?", fmt.Sprintf("$%d", argOffset+len(args))))
+	}
+
+	if f.UserID != "" {
+		add("(actor_id = $? OR (target_type = 'user' AND target_id = $?))", f.UserID)
+	}
+	if f.ActorID != "" {
+		add("actor_id = $?", f.ActorID)
+	}
+	if f.OrganizationID != "" {
+		add("organization_id = $?", f.OrganizationID)
+	}
+	if f.Action != "" {
+		if strings.Contains(f.Action, ".") {
+			add("action = $?", f.Action)
+		} else {
+			add(`action LIKE $? ESCAPE '\'`, escapeLike(f.Action)+".%")
+		}
+	}
+	if f.TargetType != "" {
+		add("target_type = $?", f.TargetType)
+	}
+	if f.TargetID != "" {
+		add("target_id = $?", f.TargetID)
+	}
+	if f.From != nil {
+		add("created_at >= $?", f.From.UTC())
+	}
+	if f.To != nil {
+		add("created_at <= $?", f.To.UTC())
+	}
+
+	if len(clauses) == 0 {
+		return "", nil
+	}
+	return " AND " + strings.Join(clauses, " AND "), args
+}
+
+// Matches reports whether e satisfies every predicate of the filter. It mirrors
+// Where for stores that do not use SQL.
+func (f AuditFilter) Matches(e *AuditEvent) bool {
+	switch {
+	case f.UserID != "" && e.ActorID != f.UserID && !(e.TargetType == AuditTargetUser && e.TargetID == f.UserID),
+		f.ActorID != "" && e.ActorID != f.ActorID,
+		f.OrganizationID != "" && e.OrganizationID != f.OrganizationID,
+		f.Action != "" && strings.Contains(f.Action, ".") && e.Action != f.Action,
+		f.Action != "" && !strings.Contains(f.Action, ".") && !strings.HasPrefix(e.Action, f.Action+"."),
+		f.TargetType != "" && e.TargetType != f.TargetType,
+		f.TargetID != "" && e.TargetID != f.TargetID,
+		f.From != nil && e.CreatedAt.Before(*f.From),
+		f.To != nil && e.CreatedAt.After(*f.To):
+		return false
+	}
+	return true
+}
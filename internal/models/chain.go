@@ -0,0 +1,173 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// chainVersion is hashed into every link so the canonical form can change
+// without old links failing verification
+const chainVersion = "tlchain/v1"
+
+// chainTimeFormat writes timestamps as wall-clock time to the microsecond,
+// which is what a TIMESTAMP column stores and returns
+const chainTimeFormat = "2006-01-02T15:04:05.000000"
+
+// ChainHead is the last link of an organization's hash chain
+type ChainHead struct {
+	OrganizationID string `json:"organization_id"`
+	Seq            int64  `json:"seq"`
+	Hash           string `json:"hash"`
+}
+
+// ChainCheckpoint is a signed record of a chain head. Rewriting links up to a
+// checkpoint, even with recomputed hashes, no longer matches its hash.
+type ChainCheckpoint struct {
+	ID             string    `json:"id"`
+	OrganizationID string    `json:"organization_id"`
+	Seq            int64     `json:"seq"`
+	Hash           string    `json:"hash"`
+	KeyID          string    `json:"key_id"`
+	Signature      string    `json:"signature"` // base64 over Payload
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// ChainBreak locates the first problem found in a chain
+type ChainBreak struct {
+	Seq           int64  `json:"seq"`
+	TransactionID string `json:"transaction_id,omitempty"`
+	Reason        string `json:"reason"`
+}
+
+// ChainReport is the outcome of verifying an organization's chain
+type ChainReport struct {
+	OrganizationID string `json:"organization_id"`
+	Valid          bool   `json:"valid"`
+	Links          int64  `json:"links"`
+	HeadHash       string `json:"head_hash,omitempty"`
+	Checkpoints    int    `json:"checkpoints"`
+	// UnverifiedCheckpoints were signed with a key other than the current one and are not relied on
+	UnverifiedCheckpoints int         `json:"unverified_checkpoints,omitempty"`
+	Broken                *ChainBreak `json:"broken,omitempty"`
+	VerifiedAt            time.Time   `json:"verified_at"`
+}
+
+// ChainLink is one link of an organization's hash chain: a transaction as it was
+// created, or a later change to its status. Exactly one of the fields is set.
+type ChainLink struct {
+	Transaction  *Transaction
+	StatusChange *StatusChange
+}
+
+// Seq returns the link's position in its chain
+func (l ChainLink) Seq() int64 {
+	if l.StatusChange != nil {
+		return l.StatusChange.ChainSeq
+	}
+	return l.Transaction.ChainSeq
+}
+
+// Hashes returns the previous link's hash as recorded by the link, and its own hash
+func (l ChainLink) Hashes() (prevHash, hash string) {
+	if l.StatusChange != nil {
+		return l.StatusChange.PrevHash, l.StatusChange.Hash
+	}
+	return l.Transaction.PrevHash, l.Transaction.Hash
+}
+
+// ContentHash recomputes the link's hash from its content and prevHash
+func (l ChainLink) ContentHash(prevHash string) string {
+	if l.StatusChange != nil {
+		return l.StatusChange.ChainHash(prevHash)
+	}
+	return l.Transaction.ChainHash(prevHash)
+}
+
+// TransactionID returns the ID of the transaction the link belongs to
+func (l ChainLink) TransactionID() string {
+	if l.StatusChange != nil {
+		return l.StatusChange.TransactionID
+	}
+	return l.Transaction.ID
+}
+
+// ChainHash returns the hex SHA-256 of the transaction's canonical content and the
+// previous link's hash. Status is left out: it changes after creation, and each
+// change is linked onto the chain in its own right; see StatusChange.ChainHash.
+func (t *Transaction) ChainHash(prevHash string) string {
+	canonical, _ := json.Marshal([]string{
+		chainVersion,
+		prevHash,
+		t.ID,
+		t.OrganizationID,
+		t.UserID,
+		t.Timestamp.Round(time.Microsecond).Format(chainTimeFormat),
+		t.SenderAccount,
+		t.ReceiverAccount,
+		strconv.FormatInt(int64(t.Amount), 10),
+		t.Currency,
+		t.TransactionType,
+	})
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
+
+// Link makes t the next link after head, an organization's empty chain having a zero head,
+// and returns the new head
+func (t *Transaction) Link(head ChainHead) ChainHead {
+	t.ChainSeq = head.Seq + 1
+	t.PrevHash = head.Hash
+	t.Hash = t.ChainHash(head.Hash)
+	return ChainHead{OrganizationID: t.OrganizationID, Seq: t.ChainSeq, Hash: t.Hash}
+}
+
+// ChainHash returns the hex SHA-256 of the status change's canonical content and
+// the previous link's hash
+func (c *StatusChange) ChainHash(prevHash string) string {
+	canonical, _ := json.Marshal([]string{
+		chainVersion,
+		"status",
+		prevHash,
+		c.TransactionID,
+		c.OrganizationID,
+		c.UserID,
+		c.From,
+		c.To,
+		c.Reason,
+		c.At.Round(time.Microsecond).Format(chainTimeFormat),
+	})
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
+
+// Link makes c the next link after head and returns the new head
+func (c *StatusChange) Link(head ChainHead) ChainHead {
+	c.ChainSeq = head.Seq + 1
+	c.PrevHash = head.Hash
+	c.Hash = c.ChainHash(head.Hash)
+	return ChainHead{OrganizationID: c.OrganizationID, Seq: c.ChainSeq, Hash: c.Hash}
+}
+
+// NewChainCheckpoint returns an unsigned checkpoint of head
+func NewChainCheckpoint(head ChainHead) *ChainCheckpoint {
+	now := time.Now().UTC().Truncate(time.Second)
+	return &ChainCheckpoint{
+		ID:             "chk_" + now.Format("20060102150405") + "_" + randomString(8),
+		OrganizationID: head.OrganizationID,
+		Seq:            head.Seq,
+		Hash:           head.Hash,
+		CreatedAt:      now,
+	}
+}
+
+// Payload returns the bytes a checkpoint's signature covers
+func (c *ChainCheckpoint) Payload() []byte {
+	return []byte(chainVersion + " checkpoint\n" +
+		c.OrganizationID + "\n" +
+		strconv.FormatInt(c.Seq, 10) + "\n" +
+		c.Hash + "\n" +
+		strconv.FormatInt(c.CreatedAt.Unix(), 10))
+}
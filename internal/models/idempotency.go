@@ -0,0 +1,27 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// IdempotencyKey records the outcome of a request made with an Idempotency-Key header
+type IdempotencyKey struct {
+	UserID       string
+	Key          string
+	RequestHash  string
+	StatusCode   int
+	ResponseBody []byte
+	CreatedAt    time.Time
+}
+
+// RequestFingerprint hashes the parts of a request that must match for a retry to be replayed.
+// Keys are per user, so the organization is included to stop a key used in one
+// organization from replaying in another.
+func RequestFingerprint(method, path, orgID string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + " " + orgID + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrCurrencyMismatch is returned when a transaction's currency differs from an account it posts to
+var ErrCurrencyMismatch = errors.New("currency does not match account")
+
+// LedgerEntry is one side of a posted transaction. Amounts are signed:
+// positive entries debit (increase) an account and negative entries credit it.
+//
+// Every transaction whose sender or receiver names one of its organization's
+// accounts posts two entries: the sender is credited and the receiver debited. A
+// side that does not name an account is posted to the organization's external
+// account for the currency. Transactions between two free-text accounts post nothing.
+type LedgerEntry struct {
+	ID            int64     `json:"id"`
+	TransactionID string    `json:"transaction_id"`
+	AccountID     string    `json:"account_id"`
+	Amount        Money     `json:"amount"`
+	PostedAt      time.Time `json:"posted_at"`
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// moneyScale is the number of decimal places stored for every amount,
+// matching the DECIMAL(15, 2) amount column
+const moneyScale = 2
+
+// maxMoney is the first value that no longer fits in DECIMAL(15, 2)
+const maxMoney Money = 1_000_000_000_000_000
+
+// errOutOfRange is returned for amounts beyond maxMoney
+var errOutOfRange = errors.New("invalid amount: out of range")
+
+// currencyExponents lists the number of minor-unit digits each currency allows.
+// None may exceed moneyScale.
+var currencyExponents = map[string]int{
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+}
+
+// Money is an exact monetary amount stored as an integer number of hundredths.
+// It is encoded in JSON as a decimal string such as "150.75".
+type Money int64
+
+// ParseMoney parses a decimal string such as "150.75" or "-3" without going through floating point
+func ParseMoney(s string) (Money, error) {
+	return parseMoney(s, moneyScale)
+}
+
+// ParseAmount parses a decimal string like ParseMoney, allowing no more decimal
+// places than the currency's minor unit has
+func ParseAmount(s, currency string) (Money, error) {
+	exponent, ok := currencyExponents[currency]
+	if !ok {
+		exponent = moneyScale
+	}
+	return parseMoney(s, exponent)
+}
+
+// parseMoney parses s, rejecting more than places decimal places
+func parseMoney(s string, places int) (Money, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	if negative || strings.HasPrefix(s, "+") {
+		// A second sign is left in place for the digit check to reject
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, errors.New("invalid amount: empty")
+	}
+	if len(frac) > places {
+		return 0, fmt.Errorf("invalid amount: at most %d decimal places allowed", places)
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return 0, fmt.Errorf("invalid amount: %q", s)
+	}
+
+	digits := whole + frac + strings.Repeat("0", moneyScale-len(frac))
+	units, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || Money(units) >= maxMoney {
+		return 0, errOutOfRange
+	}
+
+	if negative {
+		units = -units
+	}
+	return Money(units), nil
+}
+
+// String formats the amount with exactly two decimal places
+func (m Money) String() string {
+	sign := ""
+	units := int64(m)
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, units/100, units%100)
+}
+
+// IsPositive reports whether the amount is greater than zero
+func (m Money) IsPositive() bool {
+	return m > 0
+}
+
+// MarshalJSON encodes the amount as a decimal string
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON accepts either a decimal string or a bare JSON number
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan reads a DECIMAL column, which the driver returns as text
+func (m *Money) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		if v >= int64(maxMoney/100) || v <= -int64(maxMoney/100) {
+			return errOutOfRange
+		}
+		*m = Money(v * 100)
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value writes the amount as a decimal string so Postgres never sees a float
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+)
+
+// Organization roles, from most to least privileged
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+	OrgRoleViewer = "viewer"
+)
+
+// PersonalOrganizationName names the organization created with every user
+const PersonalOrganizationName = "Personal"
+
+// Organization owns transactions and accounts shared by its members
+type Organization struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Membership gives a user a role in an organization. OrganizationName is set when
+// listing a user's organizations and Email when listing an organization's members.
+type Membership struct {
+	OrganizationID   string    `json:"organization_id"`
+	OrganizationName string    `json:"organization_name,omitempty"`
+	UserID           string    `json:"user_id"`
+	Email            string    `json:"email,omitempty"`
+	Role             string    `json:"role"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// Invitation offers a role in an organization to whoever holds the token and
+// signs in with the invited email. Only the hash of the token is kept.
+type Invitation struct {
+	ID             string     `json:"id"`
+	OrganizationID string     `json:"organization_id"`
+	Email          string     `json:"email"`
+	Role           string     `json:"role"`
+	TokenHash      string     `json:"-"`
+	InvitedBy      string     `json:"invited_by"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role" validate:"required,oneof=admin member viewer"`
+}
+
+type CreateInvitationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=admin member viewer"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// NewOrganization builds an organization with a fresh ID
+func NewOrganization(name string) *Organization {
+	return &Organization{
+		ID:        newOrganizationID(),
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+}
+
+func newOrganizationID() string {
+	return "org_" + time.Now().Format("20060102150405") + "_" + randomString(8)
+}
+
+// NewInvitation creates an invitation valid for ttl and returns it with its plaintext token
+func NewInvitation(orgID, email, role, invitedBy string, ttl time.Duration) (*Invitation, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	plaintext := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	return &Invitation{
+		ID:             "inv_" + now.Format("20060102150405") + "_" + randomString(8),
+		OrganizationID: orgID,
+		Email:          email,
+		Role:           role,
+		TokenHash:      HashToken(plaintext),
+		InvitedBy:      invitedBy,
+		ExpiresAt:      now.Add(ttl),
+		CreatedAt:      now,
+	}, plaintext, nil
+}
+
+// Usable reports whether the invitation can still be accepted at the given time
+func (i *Invitation) Usable(at time.Time) bool {
+	return i.AcceptedAt == nil && at.Before(i.ExpiresAt)
+}
+
+// OrgRoleCanWrite reports whether the role may create and change records in the organization
+func OrgRoleCanWrite(role string) bool {
+	return role != OrgRoleViewer
+}
+
+// OrgRoleCanManage reports whether the role may manage members and invitations
+func OrgRoleCanManage(role string) bool {
+	return role == OrgRoleOwner || role == OrgRoleAdmin
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+// RefreshToken is a single-use credential exchanged for a new access token.
+// Only the hash of the token is kept; the plaintext is returned to the client once.
+type RefreshToken struct {
+	ID        string
+	UserID    string
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	// UsedAt is set when the token is rotated; presenting it again signals reuse
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type LogoutRequest struct {
+	// RefreshToken, if given, has its whole family revoked
+	RefreshToken string `json:"refresh_token"`
+}
+
+// NewRefreshToken creates a token in familyID valid for ttl and returns it with its plaintext.
+// An empty familyID starts a new family.
+func NewRefreshToken(userID, familyID string, ttl time.Duration) (*RefreshToken, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	plaintext := base64.RawURLEncoding.EncodeToString(b)
+
+	id := "rtk_" + time.Now().Format("20060102150405") + "_" + randomString(8)
+	if familyID == "" {
+		familyID = id
+	}
+
+	now := time.Now()
+	return &RefreshToken{
+		ID:        id,
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: HashToken(plaintext),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}, plaintext, nil
+}
+
+// HashToken returns the hex SHA-256 digest under which a token is stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Expired reports whether the token is past its expiry at the given time
+func (t *RefreshToken) Expired(at time.Time) bool {
+	return !at.Before(t.ExpiresAt)
+}
@@ -1,24 +1,138 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// Transaction statuses. Pending, Authorized and Completed transactions count
+// towards account balances; Failed, Reversed and Cancelled ones are final and do not.
+const (
+	StatusPending    = "Pending"
+	StatusAuthorized = "Authorized"
+	StatusCompleted  = "Completed"
+	StatusFailed     = "Failed"
+	StatusReversed   = "Reversed"
+	StatusCancelled  = "Cancelled"
+)
+
+// statusTransitions lists the statuses each status may move to. Statuses
+// without an entry are final.
+var statusTransitions = map[string][]string{
+	StatusPending:    {StatusAuthorized, StatusCompleted, StatusFailed, StatusCancelled},
+	StatusAuthorized: {StatusCompleted, StatusFailed, StatusCancelled},
+	StatusCompleted:  {StatusReversed},
+}
+
+// statusActions names the status each action endpoint moves a transaction to
+var statusActions = map[string]string{
+	"authorize": StatusAuthorized,
+	"complete":  StatusCompleted,
+	"fail":      StatusFailed,
+	"reverse":   StatusReversed,
+	"cancel":    StatusCancelled,
+}
 
 type Transaction struct {
 	ID              string    `json:"id"`
 	Timestamp       time.Time `json:"timestamp"`
 	SenderAccount   string    `json:"sender_account"`
 	ReceiverAccount string    `json:"receiver_account"`
-	Amount          float64   `json:"amount"`
+	Amount          Money     `json:"amount"`
 	Currency        string    `json:"currency"`
 	TransactionType string    `json:"transaction_type"`
 	Status          string    `json:"status"`
-	UserID          string    `json:"user_id"`
+	OrganizationID  string    `json:"organization_id"`
+	UserID          string    `json:"user_id"` // The member who created the transaction
+
+	// ChainSeq, PrevHash and Hash link the transaction into its organization's hash
+	// chain; see ChainHash. They are empty until the transaction is stored.
+	ChainSeq int64  `json:"chain_seq,omitempty"`
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
 }
 
 type CreateTransactionRequest struct {
-	SenderAccount   string  `json:"sender_account" validate:"required"`
-	ReceiverAccount string  `json:"receiver_account" validate:"required"`
-	Amount          float64 `json:"amount" validate:"required,gt=0"`
-	Currency        string  `json:"currency" validate:"required,oneof=USD EUR GBP"`
-	TransactionType string  `json:"transaction_type" validate:"required,oneof=Transfer Deposit Withdrawal"`
-	UserID          string  `json:"-"` // Not exposed in JSON, used internally
+	SenderAccount   string `json:"sender_account" validate:"required"`
+	ReceiverAccount string `json:"receiver_account" validate:"required"`
+	// Amount is kept as written so its decimal places can be checked against the
+	// currency; see ParseAmount. A JSON string or number is accepted.
+	Amount          json.Number `json:"amount" validate:"required"`
+	Currency        string      `json:"currency" validate:"required,oneof=USD EUR GBP"`
+	TransactionType string      `json:"transaction_type" validate:"required,oneof=Transfer Deposit Withdrawal"`
+	UserID          string      `json:"-"` // Not exposed in JSON, used internally
+}
+
+type UpdateTransactionRequest struct {
+	Status string `json:"status" validate:"required,oneof=Pending Authorized Completed Failed Reversed Cancelled"`
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+// TransitionRequest is the optional body of the status action endpoints
+type TransitionRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+// StatusChange moves a transaction from one status to another. Applied changes
+// are kept as the transaction's status history.
+type StatusChange struct {
+	TransactionID  string    `json:"transaction_id"`
+	OrganizationID string    `json:"-"`
+	UserID         string    `json:"user_id"`        // The member who made the change
+	From           string    `json:"from,omitempty"` // Empty for the status the transaction was created with
+	To             string    `json:"to"`
+	Reason         string    `json:"reason,omitempty"`
+	At             time.Time `json:"at"`
+
+	// ChainSeq, PrevHash and Hash link the change into the organization's hash
+	// chain after its transaction; see ChainHash. They are set when it is stored.
+	ChainSeq int64  `json:"chain_seq,omitempty"`
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// InitialStatus returns the history entry recording the status t was created with
+func (t *Transaction) InitialStatus(at time.Time) StatusChange {
+	return StatusChange{TransactionID: t.ID, OrganizationID: t.OrganizationID, UserID: t.UserID, To: t.Status, At: at}
+}
+
+// ReversesLedger reports whether the change undoes the transaction's ledger postings
+func (c StatusChange) ReversesLedger() bool {
+	return StatusPosts(c.From) && !StatusPosts(c.To)
+}
+
+// IsValidStatus reports whether s is a known transaction status
+func IsValidStatus(s string) bool {
+	switch s {
+	case StatusPending, StatusAuthorized, StatusCompleted, StatusFailed, StatusReversed, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// StatusPosts reports whether transactions in status s have ledger postings that
+// count towards balances
+func StatusPosts(s string) bool {
+	switch s {
+	case StatusFailed, StatusReversed, StatusCancelled:
+		return false
+	}
+	return true
+}
+
+// CanTransition reports whether a transaction may move from one status to another
+func CanTransition(from, to string) bool {
+	return slices.Contains(statusTransitions[from], to)
+}
+
+// NextStatuses returns the statuses a transaction may move to from status, none if it is final
+func NextStatuses(status string) []string {
+	return slices.Clone(statusTransitions[status])
+}
+
+// StatusForAction returns the status an action such as "complete" moves a transaction to
+func StatusForAction(action string) (string, bool) {
+	status, ok := statusActions[action]
+	return status, ok
 }
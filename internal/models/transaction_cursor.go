@@ -0,0 +1,100 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Cursor directions
+const (
+	CursorNext = "next"
+	CursorPrev = "prev"
+)
+
+// TransactionCursor marks a position in a timestamp-ordered listing.
+// It is handed to clients as an opaque string.
+type TransactionCursor struct {
+	Timestamp time.Time `json:"t"`
+	ID        string    `json:"id"`
+	Direction string    `json:"d"`
+}
+
+// NewTransactionCursor returns a cursor positioned at the given transaction
+func NewTransactionCursor(t Transaction, direction string) TransactionCursor {
+	return TransactionCursor{Timestamp: t.Timestamp, ID: t.ID, Direction: direction}
+}
+
+// Encode returns the opaque string form of the cursor
+func (c TransactionCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeTransactionCursor parses a cursor previously returned by Encode
+func DecodeTransactionCursor(s string) (*TransactionCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	var c TransactionCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == "" {
+		return nil, errors.New("invalid cursor")
+	}
+	if c.Direction != CursorNext && c.Direction != CursorPrev {
+		return nil, errors.New("invalid cursor")
+	}
+	return &c, nil
+}
+
+// Forward reports whether the cursor pages in the listing's sort order
+func (c *TransactionCursor) Forward() bool {
+	return c == nil || c.Direction == CursorNext
+}
+
+// Seek returns the keyset predicate and ORDER BY clause for fetching the page
+// after (or, for prev cursors, before) the cursor position. desc is the sort
+// direction of the listing; argOffset is the number of placeholders already used.
+func (c *TransactionCursor) Seek(desc bool, argOffset int) (string, string, []interface{}) {
+	// Pages read backwards are fetched in reverse order and flipped by the caller
+	if !c.Forward() {
+		desc = !desc
+	}
+
+	direction, cmp := "ASC", ">"
+	if desc {
+		direction, cmp = "DESC", "<"
+	}
+	orderBy := fmt.Sprintf(" ORDER BY timestamp %s, id %s", direction, direction)
+
+	if c == nil {
+		return "", orderBy, nil
+	}
+	where := fmt.Sprintf(" AND (timestamp, id) %s ($%d, $%d)", cmp, argOffset+1, argOffset+2)
+	return where, orderBy, []interface{}{c.Timestamp, c.ID}
+}
+
+// After reports whether t lies beyond the cursor in the direction it pages,
+// for a listing sorted by timestamp in the given direction. It mirrors Seek
+// for stores that do not use SQL.
+func (c *TransactionCursor) After(t *Transaction, desc bool) bool {
+	if c == nil {
+		return true
+	}
+	if !c.Forward() {
+		desc = !desc
+	}
+
+	cmp := t.Timestamp.Compare(c.Timestamp)
+	if cmp == 0 {
+		cmp = strings.Compare(t.ID, c.ID)
+	}
+	if desc {
+		return cmp < 0
+	}
+	return cmp > 0
+}
@@ -0,0 +1,244 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// sortColumns whitelists the columns transactions may be sorted by
+var sortColumns = map[string]string{
+	"timestamp":        "timestamp",
+	"amount":           "amount",
+	"currency":         "currency",
+	"transaction_type": "transaction_type",
+	"status":           "status",
+	"sender_account":   "sender_account",
+	"receiver_account": "receiver_account",
+}
+
+// TransactionFilter holds the optional predicates and ordering for listing transactions
+type TransactionFilter struct {
+	From                  *time.Time
+	To                    *time.Time
+	MinAmount             *Money
+	MaxAmount             *Money
+	Currency              string
+	TransactionType       string
+	Status                string
+	SenderAccount         string
+	SenderAccountPrefix   string
+	ReceiverAccount       string
+	ReceiverAccountPrefix string
+	SortColumn            string
+	SortDesc              bool
+}
+
+// ParseTransactionFilter builds a filter from query parameters.
+// Dates accept RFC 3339 or YYYY-MM-DD; sort takes a column name, prefixed with "-" for descending.
+func ParseTransactionFilter(q url.Values) (TransactionFilter, error) {
+	f := TransactionFilter{
+		Currency:              q.Get("currency"),
+		TransactionType:       q.Get("transaction_type"),
+		Status:                q.Get("status"),
+		SenderAccount:         q.Get("sender_account"),
+		SenderAccountPrefix:   q.Get("sender_account_prefix"),
+		ReceiverAccount:       q.Get("receiver_account"),
+		ReceiverAccountPrefix: q.Get("receiver_account_prefix"),
+		SortColumn:            "timestamp",
+		SortDesc:              true,
+	}
+
+	var err error
+	if f.From, err = parseTimeParam(q, "from"); err != nil {
+		return f, err
+	}
+	if f.To, err = parseTimeParam(q, "to"); err != nil {
+		return f, err
+	}
+	if f.MinAmount, err = parseAmountParam(q, "min_amount"); err != nil {
+		return f, err
+	}
+	if f.MaxAmount, err = parseAmountParam(q, "max_amount"); err != nil {
+		return f, err
+	}
+
+	if f.From != nil && f.To != nil && f.From.After(*f.To) {
+		return f, fmt.Errorf("from must not be after to")
+	}
+	if f.MinAmount != nil && f.MaxAmount != nil && *f.MinAmount > *f.MaxAmount {
+		return f, fmt.Errorf("min_amount must not be greater than max_amount")
+	}
+
+	if sort := q.Get("sort"); sort != "" {
+		f.SortDesc = strings.HasPrefix(sort, "-")
+		column, ok := sortColumns[strings.TrimPrefix(sort, "-")]
+		if !ok {
+			return f, fmt.Errorf("invalid sort column: %s", strings.TrimPrefix(sort, "-"))
+		}
+		f.SortColumn = column
+	}
+
+	return f, nil
+}
+
+// Where returns the SQL predicates for the filter, to be appended after a
+// "WHERE organization_id = $1" clause, along with their arguments. Placeholders are
+// numbered starting at argOffset+1.
+func (f TransactionFilter) Where(argOffset int) (string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+
+	add := func(clause string, arg interface{}) {
+		args = append(args, arg)
+		clauses = append(clauses, fmt.Sprintf(clause, argOffset+len(args)))
+	}
+
+	if f.From != nil {
+		add("timestamp >= $%d", *f.From)
+	}
+	if f.To != nil {
+		add("timestamp <= $%d", *f.To)
+	}
+	if f.MinAmount != nil {
+		add("amount >= $%d", *f.MinAmount)
+	}
+	if f.MaxAmount != nil {
+		add("amount <= $%d", *f.MaxAmount)
+	}
+	if f.Currency != "" {
+		add("currency = $%d", f.Currency)
+	}
+	if f.TransactionType != "" {
+		add("transaction_type = $%d", f.TransactionType)
+	}
+	if f.Status != "" {
+		add("status = $%d", f.Status)
+	}
+	if f.SenderAccount != "" {
+		add("sender_account = $%d", f.SenderAccount)
+	}
+	if f.SenderAccountPrefix != "" {
+		add(`sender_account LIKE $%d ESCAPE '\'`, escapeLike(f.SenderAccountPrefix)+"%")
+	}
+	if f.ReceiverAccount != "" {
+		add("receiver_account = $%d", f.ReceiverAccount)
+	}
+	if f.ReceiverAccountPrefix != "" {
+		add(`receiver_account LIKE $%d ESCAPE '\'`, escapeLike(f.ReceiverAccountPrefix)+"%")
+	}
+
+	if len(clauses) == 0 {
+		return "", nil
+	}
+	return " AND " + strings.Join(clauses, " AND "), args
+}
+
+// OrderBy returns the ORDER BY clause for the filter, using id as a tie-breaker
+func (f TransactionFilter) OrderBy() string {
+	column, ok := sortColumns[f.SortColumn]
+	if !ok {
+		column = "timestamp"
+	}
+	direction := "ASC"
+	if f.SortDesc {
+		direction = "DESC"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, id %s", column, direction, direction)
+}
+
+// Matches reports whether t satisfies every predicate of the filter. It mirrors
+// Where for stores that do not use SQL.
+func (f TransactionFilter) Matches(t *Transaction) bool {
+	switch {
+	case f.From != nil && t.Timestamp.Before(*f.From),
+		f.To != nil && t.Timestamp.After(*f.To),
+		f.MinAmount != nil && t.Amount < *f.MinAmount,
+		f.MaxAmount != nil && t.Amount > *f.MaxAmount,
+		f.Currency != "" && t.Currency != f.Currency,
+		f.TransactionType != "" && t.TransactionType != f.TransactionType,
+		f.Status != "" && t.Status != f.Status,
+		f.SenderAccount != "" && t.SenderAccount != f.SenderAccount,
+		f.SenderAccountPrefix != "" && !strings.HasPrefix(t.SenderAccount, f.SenderAccountPrefix),
+		f.ReceiverAccount != "" && t.ReceiverAccount != f.ReceiverAccount,
+		f.ReceiverAccountPrefix != "" && !strings.HasPrefix(t.ReceiverAccount, f.ReceiverAccountPrefix):
+		return false
+	}
+	return true
+}
+
+// Less reports whether a sorts before b in the filter's order. It mirrors OrderBy
+// for stores that do not use SQL.
+func (f TransactionFilter) Less(a, b *Transaction) bool {
+	var c int
+	switch f.SortColumn {
+	case "amount":
+		c = compareInt64(int64(a.Amount), int64(b.Amount))
+	case "currency":
+		c = strings.Compare(a.Currency, b.Currency)
+	case "transaction_type":
+		c = strings.Compare(a.TransactionType, b.TransactionType)
+	case "status":
+		c = strings.Compare(a.Status, b.Status)
+	case "sender_account":
+		c = strings.Compare(a.SenderAccount, b.SenderAccount)
+	case "receiver_account":
+		c = strings.Compare(a.ReceiverAccount, b.ReceiverAccount)
+	default:
+		c = a.Timestamp.Compare(b.Timestamp)
+	}
+	if c == 0 {
+		c = strings.Compare(a.ID, b.ID)
+	}
+	if f.SortDesc {
+		return c > 0
+	}
+	return c < 0
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func parseTimeParam(q url.Values, key string) (*time.Time, error) {
+	value := q.Get(key)
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: expected RFC 3339 or YYYY-MM-DD", key)
+	}
+	// A bare date as an upper bound covers the whole day
+	if key == "to" {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &t, nil
+}
+
+func parseAmountParam(q url.Values, key string) (*Money, error) {
+	value := q.Get(key)
+	if value == "" {
+		return nil, nil
+	}
+	amount, err := ParseMoney(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", key, value)
+	}
+	return &amount, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
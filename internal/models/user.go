@@ -1,20 +1,38 @@
 package models
 
 import (
-	"database/sql"
-	"errors"
 	"math/rand"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+// User roles, from least to most privileged
+const (
+	RoleUser    = "user"
+	RoleAuditor = "auditor"
+	RoleAdmin   = "admin"
+)
+
 type User struct {
-	ID        string    `json:"id"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"` // Don't include password in JSON
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         string     `json:"id"`
+	Email      string     `json:"email"`
+	Password   string     `json:"-"` // Don't include password in JSON
+	Role       string     `json:"role"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+	// EmailVerifiedAt is set once the user follows a verification or password reset email
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// TOTPSecret is set during enrollment and only used for login once TOTPEnabledAt is set
+	TOTPSecret    string     `json:"-"`
+	TOTPEnabledAt *time.Time `json:"totp_enabled_at,omitempty"`
+	// DefaultOrganizationID is used when a request does not select an organization
+	DefaultOrganizationID string    `json:"default_organization_id,omitempty"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+type UpdateRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=user auditor admin"`
 }
 
 type RegisterRequest struct {
@@ -46,49 +64,24 @@ func CheckPassword(hashedPassword, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
 
-// CreateUser creates a new user in the database
-func CreateUser(db *sql.DB, email, password string) (*User, error) {
+// NewUser builds a user with a fresh ID and a hashed copy of the password.
+// DefaultOrganizationID names the personal organization created with the user.
+func NewUser(email, password string) (*User, error) {
 	hashedPassword, err := HashPassword(password)
 	if err != nil {
 		return nil, err
 	}
 
-	user := &User{
-		ID:        generateID(),
-		Email:     email,
-		Password:  hashedPassword,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-
-	_, err = db.Exec(
-		"INSERT INTO users (id, email, password, created_at, updated_at) VALUES ($1, $2, $3, $4, $5)",
-		user.ID, user.Email, user.Password, user.CreatedAt, user.UpdatedAt,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return user, nil
-}
-
-// GetUserByEmail retrieves a user by email
-func GetUserByEmail(db *sql.DB, email string) (*User, error) {
-	user := &User{}
-	err := db.QueryRow(
-		"SELECT id, email, password, created_at, updated_at FROM users WHERE email = $1",
-		email,
-	).Scan(&user.ID, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("user not found")
-		}
-		return nil, err
-	}
-
-	return user, nil
+	now := time.Now()
+	return &User{
+		ID:                    generateID(),
+		Email:                 email,
+		Password:              hashedPassword,
+		Role:                  RoleUser,
+		DefaultOrganizationID: newOrganizationID(),
+		CreatedAt:             now,
+		UpdatedAt:             now,
+	}, nil
 }
 
 func generateID() string {
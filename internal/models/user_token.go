@@ -0,0 +1,63 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+)
+
+// User token purposes
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposeResetPassword = "reset_password"
+)
+
+// UserToken is a single-use token emailed to a user to prove they control their
+// address. Only the hash is kept; the plaintext only ever appears in the email.
+type UserToken struct {
+	ID        string
+	UserID    string
+	Purpose   string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// EmailRequest names the address a verification or password reset email is sent to
+type EmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8"`
+}
+
+// NewUserToken creates a token for purpose valid for ttl and returns it with its plaintext
+func NewUserToken(userID, purpose string, ttl time.Duration) (*UserToken, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	plaintext := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	return &UserToken{
+		ID:        "utk_" + now.Format("20060102150405") + "_" + randomString(8),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: HashToken(plaintext),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}, plaintext, nil
+}
+
+// Usable reports whether the token can still be redeemed at the given time
+func (t *UserToken) Usable(at time.Time) bool {
+	return t.UsedAt == nil && at.Before(t.ExpiresAt)
+}
@@ -0,0 +1,106 @@
+// Package ratelimit implements the token bucket behind the API's rate limits.
+// Buckets are persisted by a store.RateLimitStore so limits can be shared by
+// every instance of the server.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests requests per Per, in bursts of up to Requests. The zero
+// Limit is disabled.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// Parse reads a limit written as "requests/duration", such as "100/1m". An empty
+// string or "0" disables the limit.
+func Parse(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return Limit{}, nil
+	}
+	requests, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q: expected requests/duration", s)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: invalid request count", s)
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: invalid duration", s)
+	}
+	return Limit{Requests: n, Per: d}, nil
+}
+
+// Enabled reports whether the limit restricts anything
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Per > 0
+}
+
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "0"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+}
+
+// rate returns how many tokens are added per second
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// Bucket is the state of one caller's token bucket
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// NewBucket returns a full bucket
+func NewBucket(limit Limit, at time.Time) Bucket {
+	return Bucket{Tokens: float64(limit.Requests), UpdatedAt: at}
+}
+
+// Result is the outcome of taking a token
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until a token is available; zero when Allowed
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// Take refills the bucket for the time since it was last updated and removes a
+// token if one is available
+func (b *Bucket) Take(limit Limit, at time.Time) Result {
+	capacity := float64(limit.Requests)
+	rate := limit.rate()
+	if at.After(b.UpdatedAt) {
+		b.Tokens = math.Min(capacity, b.Tokens+at.Sub(b.UpdatedAt).Seconds()*rate)
+		b.UpdatedAt = at
+	}
+
+	res := Result{Limit: limit.Requests}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.Tokens) / rate)
+	}
+	res.Remaining = int(math.Floor(b.Tokens))
+	res.Reset = seconds((capacity - b.Tokens) / rate)
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+)
+
+// CreateAPIKey stores a copy of key
+func (s *Store) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.apiKeys {
+		if existing.KeyHash == key.KeyHash {
+			return store.ErrAlreadyExists
+		}
+	}
+	k := copyAPIKey(key)
+	s.apiKeys[k.ID] = k
+	return nil
+}
+
+// ListAPIKeys returns copies of the user's keys, newest first
+func (s *Store) ListAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := []models.APIKey{}
+	for _, key := range s.apiKeys {
+		if key.UserID == userID {
+			keys = append(keys, *copyAPIKey(key))
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.After(keys[j].CreatedAt)
+		}
+		return keys[i].ID > keys[j].ID
+	})
+	return keys, nil
+}
+
+// GetAPIKeyByHash returns a copy of the key with the hash
+func (s *Store) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.apiKeys {
+		if key.KeyHash == hash {
+			return copyAPIKey(key), nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+// RevokeAPIKey marks one of the user's keys revoked
+func (s *Store) RevokeAPIKey(ctx context.Context, userID, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.apiKeys[id]
+	if !ok || key.UserID != userID || key.RevokedAt != nil {
+		return store.ErrNotFound
+	}
+	key.RevokedAt = &at
+	return nil
+}
+
+// TouchAPIKey sets the key's last-used time
+func (s *Store) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.apiKeys[id]; ok {
+		key.LastUsedAt = &at
+	}
+	return nil
+}
+
+// copyAPIKey copies key so callers cannot modify the stored scopes
+func copyAPIKey(key *models.APIKey) *models.APIKey {
+	k := *key
+	k.Scopes = append([]string(nil), key.Scopes...)
+	return &k
+}
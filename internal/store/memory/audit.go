@@ -0,0 +1,36 @@
+package memory
+
+import (
+	"context"
+
+	"transaction-logger/internal/models"
+)
+
+// CreateAuditEvent appends a copy of e
+func (s *Store) CreateAuditEvent(ctx context.Context, e *models.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.auditEvents = append(s.auditEvents, *e)
+	return nil
+}
+
+// ListAuditEvents returns one page of matching events, newest first
+func (s *Store) ListAuditEvents(ctx context.Context, filter models.AuditFilter, limit, offset int) ([]models.AuditEvent, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matched []models.AuditEvent
+	for i := len(s.auditEvents) - 1; i >= 0; i-- {
+		if filter.Matches(&s.auditEvents[i]) {
+			matched = append(matched, s.auditEvents[i])
+		}
+	}
+
+	total := len(matched)
+	page := []models.AuditEvent{}
+	if offset < total {
+		page = append(page, matched[offset:min(offset+limit, total)]...)
+	}
+	return page, total, nil
+}
@@ -0,0 +1,137 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+)
+
+// ListChainLinks returns up to limit of the organization's transactions and status
+// changes after seq, as copies
+func (s *Store) ListChainLinks(ctx context.Context, orgID string, afterSeq int64, limit int) ([]models.ChainLink, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	links := []models.ChainLink{}
+	for _, t := range s.transactions {
+		if t.OrganizationID != orgID {
+			continue
+		}
+		if t.ChainSeq > afterSeq {
+			copied := *t
+			links = append(links, models.ChainLink{Transaction: &copied})
+		}
+		for _, c := range s.history[t.ID] {
+			if c.ChainSeq > afterSeq {
+				copied := c
+				links = append(links, models.ChainLink{StatusChange: &copied})
+			}
+		}
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].Seq() < links[j].Seq() })
+	if len(links) > limit {
+		links = links[:limit]
+	}
+	return links, nil
+}
+
+// GetChainHead returns the last link of the organization's chain
+func (s *Store) GetChainHead(ctx context.Context, orgID string) (models.ChainHead, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.chainHead(orgID), nil
+}
+
+// ListChainHeads returns the last link of every non-empty chain, ordered by organization
+func (s *Store) ListChainHeads(ctx context.Context) ([]models.ChainHead, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	heads := []models.ChainHead{}
+	for _, head := range s.chainHeads {
+		heads = append(heads, head)
+	}
+	sort.Slice(heads, func(i, j int) bool { return heads[i].OrganizationID < heads[j].OrganizationID })
+	return heads, nil
+}
+
+// LinkTransactions links unlinked transactions and their current status, oldest first
+func (s *Store) LinkTransactions(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var unlinked []*models.Transaction
+	for _, t := range s.transactions {
+		if t.Hash == "" {
+			unlinked = append(unlinked, t)
+		}
+	}
+	sort.Slice(unlinked, func(i, j int) bool {
+		if !unlinked[i].Timestamp.Equal(unlinked[j].Timestamp) {
+			return unlinked[i].Timestamp.Before(unlinked[j].Timestamp)
+		}
+		return unlinked[i].ID < unlinked[j].ID
+	})
+	now := time.Now()
+	for _, t := range unlinked {
+		s.link(t)
+		s.linkStatus(t.InitialStatus(now))
+	}
+	return int64(len(unlinked)), nil
+}
+
+// CreateChainCheckpoint stores a copy of cp
+func (s *Store) CreateChainCheckpoint(ctx context.Context, cp *models.ChainCheckpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.checkpoints {
+		if existing.OrganizationID == cp.OrganizationID && existing.Seq == cp.Seq {
+			return store.ErrAlreadyExists
+		}
+	}
+	s.checkpoints = append(s.checkpoints, *cp)
+	return nil
+}
+
+// ListChainCheckpoints returns the organization's checkpoints, newest first
+func (s *Store) ListChainCheckpoints(ctx context.Context, orgID string, limit int) ([]models.ChainCheckpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	checkpoints := []models.ChainCheckpoint{}
+	for _, cp := range s.checkpoints {
+		if cp.OrganizationID == orgID {
+			checkpoints = append(checkpoints, cp)
+		}
+	}
+	sort.Slice(checkpoints, func(i, j int) bool { return checkpoints[i].Seq > checkpoints[j].Seq })
+	if limit > 0 && len(checkpoints) > limit {
+		checkpoints = checkpoints[:limit]
+	}
+	return checkpoints, nil
+}
+
+// link appends t to its organization's chain. The caller must hold the write lock.
+func (s *Store) link(t *models.Transaction) {
+	s.chainHeads[t.OrganizationID] = t.Link(s.chainHead(t.OrganizationID))
+}
+
+// linkStatus appends c to its organization's chain and its transaction's history.
+// The caller must hold the write lock.
+func (s *Store) linkStatus(c models.StatusChange) {
+	s.chainHeads[c.OrganizationID] = c.Link(s.chainHead(c.OrganizationID))
+	s.history[c.TransactionID] = append(s.history[c.TransactionID], c)
+}
+
+// chainHead returns the organization's head, zero for an empty chain. The caller must hold the lock.
+func (s *Store) chainHead(orgID string) models.ChainHead {
+	if head, ok := s.chainHeads[orgID]; ok {
+		return head
+	}
+	return models.ChainHead{OrganizationID: orgID}
+}
@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"time"
+)
+
+type loginAttempt struct {
+	failures      int
+	lastFailureAt time.Time
+	lockedUntil   time.Time
+}
+
+// RecordLoginFailure counts a failure for key
+func (s *Store) RecordLoginFailure(ctx context.Context, key string, at, resetBefore time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.loginAttempts[key]
+	if !ok {
+		attempt = &loginAttempt{}
+		s.loginAttempts[key] = attempt
+	}
+	if attempt.lastFailureAt.Before(resetBefore) {
+		attempt.failures = 0
+	}
+	attempt.failures++
+	attempt.lastFailureAt = at
+	return attempt.failures, nil
+}
+
+// LockLogin blocks key until the given time, keeping any later lock
+func (s *Store) LockLogin(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if attempt, ok := s.loginAttempts[key]; ok && until.After(attempt.lockedUntil) {
+		attempt.lockedUntil = until
+	}
+	return nil
+}
+
+// LoginLockedUntil returns the latest lock on any of keys still in force
+func (s *Store) LoginLockedUntil(ctx context.Context, keys []string, at time.Time) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var until time.Time
+	for _, key := range keys {
+		if attempt, ok := s.loginAttempts[key]; ok && attempt.lockedUntil.After(at) && attempt.lockedUntil.After(until) {
+			until = attempt.lockedUntil
+		}
+	}
+	return until, nil
+}
+
+// ClearLoginFailures forgets key
+func (s *Store) ClearLoginFailures(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.loginAttempts, key)
+	return nil
+}
+
+// PurgeLoginAttempts deletes stale records whose lock has ended
+func (s *Store) PurgeLoginAttempts(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var purged int64
+	for key, attempt := range s.loginAttempts {
+		if attempt.lastFailureAt.Before(before) && !attempt.lockedUntil.After(now) {
+			delete(s.loginAttempts, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,593 @@
+// Package memory implements the store interfaces in process memory. It is meant
+// for handler tests and local demos; nothing survives a restart.
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/ratelimit"
+	"transaction-logger/internal/store"
+)
+
+// Store implements store.Store with maps guarded by a single lock
+type Store struct {
+	mu             sync.RWMutex
+	idempotencyTTL time.Duration
+
+	users        map[string]*models.User // keyed by ID
+	usersByEmail map[string]string       // email to ID
+	transactions map[string]*models.Transaction
+	accounts     map[string]*models.Account
+	entries      []models.LedgerEntry
+	history      map[string][]models.StatusChange // keyed by transaction ID, oldest first
+	chainHeads   map[string]models.ChainHead      // keyed by organization ID
+	checkpoints  []models.ChainCheckpoint
+	idempotency  map[idempotencyID]*models.IdempotencyKey
+
+	refreshTokens map[string]*models.RefreshToken // keyed by hash
+	revokedJTIs   map[string]time.Time            // jti to expiry
+	apiKeys       map[string]*models.APIKey       // keyed by ID
+	userTokens    map[string]*models.UserToken    // keyed by hash
+	totpSteps     map[string]int64                // user ID to last accepted step
+	recoveryCodes map[string]map[string]bool      // user ID to unused code hashes
+	loginAttempts map[string]*loginAttempt
+	rateLimits    map[string]*ratelimit.Bucket
+
+	organizations map[string]*models.Organization
+	members       map[memberID]*models.Membership
+	invitations   map[string]*models.Invitation // keyed by ID
+
+	auditEvents []models.AuditEvent // oldest first
+}
+
+type idempotencyID struct {
+	userID, key string
+}
+
+var _ store.Store = (*Store)(nil)
+
+// New returns an empty Store. Idempotency records older than idempotencyTTL are ignored and purged.
+func New(idempotencyTTL time.Duration) *Store {
+	return &Store{
+		idempotencyTTL: idempotencyTTL,
+		users:          map[string]*models.User{},
+		usersByEmail:   map[string]string{},
+		transactions:   map[string]*models.Transaction{},
+		accounts:       map[string]*models.Account{},
+		history:        map[string][]models.StatusChange{},
+		chainHeads:     map[string]models.ChainHead{},
+		idempotency:    map[idempotencyID]*models.IdempotencyKey{},
+		refreshTokens:  map[string]*models.RefreshToken{},
+		revokedJTIs:    map[string]time.Time{},
+		apiKeys:        map[string]*models.APIKey{},
+		userTokens:     map[string]*models.UserToken{},
+		totpSteps:      map[string]int64{},
+		recoveryCodes:  map[string]map[string]bool{},
+		loginAttempts:  map[string]*loginAttempt{},
+		rateLimits:     map[string]*ratelimit.Bucket{},
+		organizations:  map[string]*models.Organization{},
+		members:        map[memberID]*models.Membership{},
+		invitations:    map[string]*models.Invitation{},
+	}
+}
+
+// CreateUser stores a copy of user along with their personal organization
+func (s *Store) CreateUser(ctx context.Context, user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.usersByEmail[user.Email]; ok {
+		return store.ErrAlreadyExists
+	}
+	if _, ok := s.organizations[user.DefaultOrganizationID]; ok {
+		return store.ErrAlreadyExists
+	}
+	u := *user
+	s.users[u.ID] = &u
+	s.usersByEmail[u.Email] = u.ID
+
+	s.addOrganization(
+		&models.Organization{ID: u.DefaultOrganizationID, Name: models.PersonalOrganizationName, CreatedAt: u.CreatedAt},
+		&models.Membership{OrganizationID: u.DefaultOrganizationID, UserID: u.ID, Role: models.OrgRoleOwner, CreatedAt: u.CreatedAt},
+	)
+	return nil
+}
+
+// GetUserByEmail returns a copy of the user with the email
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.usersByEmail[email]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	u := *s.users[id]
+	return &u, nil
+}
+
+// GetUserByID returns a copy of the user with the ID
+func (s *Store) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	u := *user
+	return &u, nil
+}
+
+// ListUsers returns copies of one page of users, oldest first, and the total number of users
+func (s *Store) ListUsers(ctx context.Context, limit, offset int) ([]models.User, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]models.User, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, *u)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if !users[i].CreatedAt.Equal(users[j].CreatedAt) {
+			return users[i].CreatedAt.Before(users[j].CreatedAt)
+		}
+		return users[i].ID < users[j].ID
+	})
+
+	total := len(users)
+	if offset >= total {
+		return []models.User{}, total, nil
+	}
+	return users[offset:min(offset+limit, total)], total, nil
+}
+
+// SetUserRole changes a user's role
+func (s *Store) SetUserRole(ctx context.Context, id, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	user.Role = role
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+// SetUserDisabled sets or clears a user's disabled time
+func (s *Store) SetUserDisabled(ctx context.Context, id string, at *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	user.DisabledAt = at
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+// SetUserPassword replaces a user's password hash
+func (s *Store) SetUserPassword(ctx context.Context, id, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	user.Password = passwordHash
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+// SetEmailVerified records when a user's email was verified, keeping an earlier time
+func (s *Store) SetEmailVerified(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if user.EmailVerifiedAt == nil {
+		user.EmailVerifiedAt = &at
+	}
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+// ListTransactions returns one page of the organization's matching transactions and the total number of matches
+func (s *Store) ListTransactions(ctx context.Context, orgID string, filter models.TransactionFilter, limit, offset int) ([]models.Transaction, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matches := s.matching(orgID, filter, filter.Less)
+	total := len(matches)
+	if offset >= total {
+		return nil, total, nil
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return matches[offset:end], total, nil
+}
+
+// ListTransactionsByCursor returns up to limit matching transactions after the cursor
+func (s *Store) ListTransactionsByCursor(ctx context.Context, orgID string, filter models.TransactionFilter, cursor *models.TransactionCursor, limit int) ([]models.Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Pages read backwards are returned in reverse order, as the SQL store does
+	order := filter
+	order.SortColumn = "timestamp"
+	if !cursor.Forward() {
+		order.SortDesc = !order.SortDesc
+	}
+
+	var page []models.Transaction
+	for _, t := range s.matching(orgID, filter, order.Less) {
+		if !cursor.After(&t, filter.SortDesc) {
+			continue
+		}
+		page = append(page, t)
+		if len(page) == limit {
+			break
+		}
+	}
+	return page, nil
+}
+
+// ExportTransactions calls fn for every matching transaction in order
+func (s *Store) ExportTransactions(ctx context.Context, orgID string, filter models.TransactionFilter, fn func(*models.Transaction) error) error {
+	s.mu.RLock()
+	matches := s.matching(orgID, filter, filter.Less)
+	s.mu.RUnlock()
+
+	for i := range matches {
+		if err := fn(&matches[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTransaction returns a copy of the organization's transaction
+func (s *Store) GetTransaction(ctx context.Context, orgID, id string) (*models.Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.transactions[id]
+	if !ok || t.OrganizationID != orgID {
+		return nil, store.ErrNotFound
+	}
+	copied := *t
+	return &copied, nil
+}
+
+// ListStatusHistory returns copies of the transaction's status changes, oldest first
+func (s *Store) ListStatusHistory(ctx context.Context, orgID, id string) ([]models.StatusChange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if t, ok := s.transactions[id]; !ok || t.OrganizationID != orgID {
+		return nil, store.ErrNotFound
+	}
+	return append([]models.StatusChange{}, s.history[id]...), nil
+}
+
+// CreateTransaction stores t, posts its ledger entries and records idem
+func (s *Store) CreateTransaction(ctx context.Context, t *models.Transaction, idem *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if idem != nil {
+		id := idempotencyID{idem.UserID, idem.Key}
+		if existing, ok := s.idempotency[id]; ok && time.Since(existing.CreatedAt) < s.idempotencyTTL {
+			copied := *existing
+			return &copied, nil
+		}
+	}
+
+	if _, exists := s.transactions[t.ID]; exists {
+		return nil, store.ErrAlreadyExists
+	}
+	if err := s.post(t); err != nil {
+		return nil, err
+	}
+	s.link(t)
+	s.linkStatus(t.InitialStatus(time.Now()))
+	copied := *t
+	s.transactions[t.ID] = &copied
+
+	if idem != nil {
+		var err error
+		if idem.ResponseBody, err = json.Marshal(t); err != nil {
+			return nil, err
+		}
+		stored := *idem
+		s.idempotency[idempotencyID{idem.UserID, idem.Key}] = &stored
+	}
+	return nil, nil
+}
+
+// UpdateTransactionStatus applies change if the transaction is still in change.From
+func (s *Store) UpdateTransactionStatus(ctx context.Context, change models.StatusChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.transactions[change.TransactionID]
+	if !ok || t.OrganizationID != change.OrganizationID || t.Status != change.From {
+		return store.ErrConflict
+	}
+	t.Status = change.To
+	s.linkStatus(change)
+
+	if change.ReversesLedger() {
+		s.reverse(change.TransactionID, change.At)
+	}
+	return nil
+}
+
+// PurgeIdempotencyKeys deletes expired idempotency records
+func (s *Store) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for id, k := range s.idempotency {
+		if time.Since(k.CreatedAt) >= s.idempotencyTTL {
+			delete(s.idempotency, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// BeginImport starts a bulk insert that is applied in full on Commit
+func (s *Store) BeginImport(ctx context.Context) (store.TransactionImport, error) {
+	return &transactionImport{store: s}, nil
+}
+
+type transactionImport struct {
+	store   *Store
+	pending []models.Transaction
+	changes []models.StatusChange // applied after pending, in order
+	done    bool
+}
+
+func (i *transactionImport) Check(ctx context.Context, t *models.Transaction) error {
+	i.store.mu.RLock()
+	defer i.store.mu.RUnlock()
+
+	if _, err := i.store.ledgerAccount(t.OrganizationID, t.SenderAccount, t.Currency); err != nil {
+		return err
+	}
+	_, err := i.store.ledgerAccount(t.OrganizationID, t.ReceiverAccount, t.Currency)
+	return err
+}
+
+func (i *transactionImport) Insert(ctx context.Context, transactions []models.Transaction) error {
+	i.pending = append(i.pending, transactions...)
+	return nil
+}
+
+func (i *transactionImport) Transition(ctx context.Context, change models.StatusChange) error {
+	i.changes = append(i.changes, change)
+	return nil
+}
+
+func (i *transactionImport) Commit() error {
+	if i.done {
+		return nil
+	}
+	i.done = true
+
+	s := i.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Check everything first so a failed commit leaves no partial import behind
+	staged := map[string]models.Transaction{}
+	for j := range i.pending {
+		t := &i.pending[j]
+		if _, exists := s.transactions[t.ID]; exists {
+			return store.ErrAlreadyExists
+		}
+		if _, err := s.ledgerAccount(t.OrganizationID, t.SenderAccount, t.Currency); err != nil {
+			return err
+		}
+		if _, err := s.ledgerAccount(t.OrganizationID, t.ReceiverAccount, t.Currency); err != nil {
+			return err
+		}
+		staged[t.ID] = *t
+	}
+	for _, c := range i.changes {
+		t, ok := staged[c.TransactionID]
+		if !ok || t.OrganizationID != c.OrganizationID || t.Status != c.From {
+			return store.ErrConflict
+		}
+		t.Status = c.To
+		staged[t.ID] = t
+	}
+
+	now := time.Now()
+	for j := range i.pending {
+		t := i.pending[j]
+		s.post(&t) // cannot fail: the accounts were checked above
+		s.link(&t)
+		s.linkStatus(t.InitialStatus(now))
+		s.transactions[t.ID] = &t
+	}
+	for _, c := range i.changes {
+		s.transactions[c.TransactionID].Status = c.To
+		s.linkStatus(c)
+		if c.ReversesLedger() {
+			s.reverse(c.TransactionID, c.At)
+		}
+	}
+	return nil
+}
+
+func (i *transactionImport) Rollback() error {
+	i.done = true
+	i.pending = nil
+	i.changes = nil
+	return nil
+}
+
+// CreateAccount stores a copy of account
+func (s *Store) CreateAccount(ctx context.Context, account *models.Account) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.accounts[account.ID]; ok {
+		return store.ErrAlreadyExists
+	}
+	a := *account
+	s.accounts[a.ID] = &a
+	return nil
+}
+
+// GetAccount returns a copy of the organization's account
+func (s *Store) GetAccount(ctx context.Context, orgID, id string) (*models.Account, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a, ok := s.accounts[id]
+	if !ok || a.OrganizationID != orgID {
+		return nil, models.ErrAccountNotFound
+	}
+	copied := *a
+	return &copied, nil
+}
+
+// ListAccounts returns the organization's accounts, oldest first
+func (s *Store) ListAccounts(ctx context.Context, orgID string) ([]models.Account, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	accounts := []models.Account{}
+	for _, a := range s.accounts {
+		if a.OrganizationID == orgID {
+			accounts = append(accounts, *a)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		if !accounts[i].CreatedAt.Equal(accounts[j].CreatedAt) {
+			return accounts[i].CreatedAt.Before(accounts[j].CreatedAt)
+		}
+		return accounts[i].ID < accounts[j].ID
+	})
+	return accounts, nil
+}
+
+// AccountBalance sums the account's ledger entries posted at or before asOf
+func (s *Store) AccountBalance(ctx context.Context, account *models.Account, asOf time.Time) (*models.Balance, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	balance := &models.Balance{AccountID: account.ID, Currency: account.Currency, AsOf: asOf}
+	for _, e := range s.entries {
+		if e.AccountID == account.ID && !e.PostedAt.After(asOf) {
+			balance.Balance += e.Amount
+		}
+	}
+	return balance, nil
+}
+
+// matching returns copies of the organization's transactions accepted by filter, sorted by less.
+// The caller must hold the lock.
+func (s *Store) matching(orgID string, filter models.TransactionFilter, less func(a, b *models.Transaction) bool) []models.Transaction {
+	var matches []models.Transaction
+	for _, t := range s.transactions {
+		if t.OrganizationID == orgID && filter.Matches(t) {
+			matches = append(matches, *t)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return less(&matches[i], &matches[j]) })
+	return matches
+}
+
+// post writes balanced ledger entries for t, following the same rules as the
+// Postgres store. The caller must hold the write lock.
+func (s *Store) post(t *models.Transaction) error {
+	sender, err := s.ledgerAccount(t.OrganizationID, t.SenderAccount, t.Currency)
+	if err != nil {
+		return err
+	}
+	receiver, err := s.ledgerAccount(t.OrganizationID, t.ReceiverAccount, t.Currency)
+	if err != nil {
+		return err
+	}
+	if (sender == "" && receiver == "") || !models.StatusPosts(t.Status) {
+		return nil
+	}
+
+	for _, side := range []*string{&sender, &receiver} {
+		if *side != "" {
+			continue
+		}
+		external := models.NewExternalAccount(t.OrganizationID, t.UserID, t.Currency)
+		if _, ok := s.accounts[external.ID]; !ok {
+			s.accounts[external.ID] = external
+		}
+		*side = external.ID
+	}
+
+	s.addEntry(t.ID, sender, -t.Amount, t.Timestamp)
+	s.addEntry(t.ID, receiver, t.Amount, t.Timestamp)
+	return nil
+}
+
+// reverse offsets everything posted for the transaction. The caller must hold the write lock.
+func (s *Store) reverse(transactionID string, at time.Time) {
+	sums := map[string]models.Money{}
+	var order []string
+	for _, e := range s.entries {
+		if e.TransactionID != transactionID {
+			continue
+		}
+		if _, seen := sums[e.AccountID]; !seen {
+			order = append(order, e.AccountID)
+		}
+		sums[e.AccountID] += e.Amount
+	}
+	for _, accountID := range order {
+		if sums[accountID] != 0 {
+			s.addEntry(transactionID, accountID, -sums[accountID], at)
+		}
+	}
+}
+
+func (s *Store) addEntry(transactionID, accountID string, amount models.Money, at time.Time) {
+	s.entries = append(s.entries, models.LedgerEntry{
+		ID:            int64(len(s.entries) + 1),
+		TransactionID: transactionID,
+		AccountID:     accountID,
+		Amount:        amount,
+		PostedAt:      at,
+	})
+}
+
+// ledgerAccount resolves an account reference to one of the organization's accounts,
+// returning "" when the reference is free text. The caller must hold the lock.
+func (s *Store) ledgerAccount(orgID, ref, currency string) (string, error) {
+	a, ok := s.accounts[ref]
+	if !ok || a.OrganizationID != orgID {
+		return "", nil
+	}
+	if err := a.CheckCurrency(currency); err != nil {
+		return "", err
+	}
+	return a.ID, nil
+}
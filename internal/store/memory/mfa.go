@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"transaction-logger/internal/store"
+)
+
+// SetTOTPSecret stores a pending TOTP secret unless TOTP is already enabled
+func (s *Store) SetTOTPSecret(ctx context.Context, userID, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok || user.TOTPEnabledAt != nil {
+		return store.ErrConflict
+	}
+	user.TOTPSecret = secret
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+// EnableTOTP enables the pending secret and replaces the recovery codes
+func (s *Store) EnableTOTP(ctx context.Context, userID string, at time.Time, recoveryCodeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok || user.TOTPSecret == "" || user.TOTPEnabledAt != nil {
+		return store.ErrConflict
+	}
+	user.TOTPEnabledAt = &at
+	user.UpdatedAt = at
+	delete(s.totpSteps, userID)
+	s.replaceRecoveryCodes(userID, recoveryCodeHashes)
+	return nil
+}
+
+// DisableTOTP clears the TOTP secret and deletes the recovery codes
+func (s *Store) DisableTOTP(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.users[userID]; ok {
+		user.TOTPSecret = ""
+		user.TOTPEnabledAt = nil
+		user.UpdatedAt = time.Now()
+	}
+	delete(s.totpSteps, userID)
+	delete(s.recoveryCodes, userID)
+	return nil
+}
+
+// UseTOTPStep advances the user's last accepted step, refusing to move it backwards or repeat it
+func (s *Store) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if step <= s.totpSteps[userID] {
+		return store.ErrConflict
+	}
+	s.totpSteps[userID] = step
+	return nil
+}
+
+// ReplaceRecoveryCodes discards the user's recovery codes and stores new ones
+func (s *Store) ReplaceRecoveryCodes(ctx context.Context, userID string, hashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.replaceRecoveryCodes(userID, hashes)
+	return nil
+}
+
+// ConsumeRecoveryCode deletes an unused recovery code
+func (s *Store) ConsumeRecoveryCode(ctx context.Context, userID, hash string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.recoveryCodes[userID][hash] {
+		return store.ErrNotFound
+	}
+	delete(s.recoveryCodes[userID], hash)
+	return nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes the user has left
+func (s *Store) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.recoveryCodes[userID]), nil
+}
+
+// replaceRecoveryCodes stores hashes as the user's only codes. The caller must hold the write lock.
+func (s *Store) replaceRecoveryCodes(userID string, hashes []string) {
+	codes := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		codes[hash] = true
+	}
+	s.recoveryCodes[userID] = codes
+}
@@ -0,0 +1,187 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+)
+
+type memberID struct {
+	orgID, userID string
+}
+
+// CreateOrganization stores copies of org and its owner
+func (s *Store) CreateOrganization(ctx context.Context, org *models.Organization, owner *models.Membership) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.organizations[org.ID]; ok {
+		return store.ErrAlreadyExists
+	}
+	s.addOrganization(org, owner)
+	return nil
+}
+
+// GetMembership returns a copy of the user's membership of the organization
+func (s *Store) GetMembership(ctx context.Context, orgID, userID string) (*models.Membership, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.members[memberID{orgID, userID}]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	copied := *m
+	copied.OrganizationName = s.organizations[orgID].Name
+	return &copied, nil
+}
+
+// ListMemberships returns the user's memberships, oldest first
+func (s *Store) ListMemberships(ctx context.Context, userID string) ([]models.Membership, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	memberships := []models.Membership{}
+	for id, m := range s.members {
+		if id.userID == userID {
+			copied := *m
+			copied.OrganizationName = s.organizations[id.orgID].Name
+			memberships = append(memberships, copied)
+		}
+	}
+	sortMemberships(memberships, func(m *models.Membership) string { return m.OrganizationID })
+	return memberships, nil
+}
+
+// ListMembers returns the organization's members, oldest first
+func (s *Store) ListMembers(ctx context.Context, orgID string) ([]models.Membership, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	members := []models.Membership{}
+	for id, m := range s.members {
+		if id.orgID == orgID {
+			copied := *m
+			copied.Email = s.users[id.userID].Email
+			members = append(members, copied)
+		}
+	}
+	sortMemberships(members, func(m *models.Membership) string { return m.UserID })
+	return members, nil
+}
+
+// SetMemberRole changes a member's role
+func (s *Store) SetMemberRole(ctx context.Context, orgID, userID, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.members[memberID{orgID, userID}]
+	if !ok {
+		return store.ErrNotFound
+	}
+	m.Role = role
+	return nil
+}
+
+// RemoveMember deletes a membership
+func (s *Store) RemoveMember(ctx context.Context, orgID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := memberID{orgID, userID}
+	if _, ok := s.members[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.members, id)
+	return nil
+}
+
+// CreateInvitation stores a copy of inv
+func (s *Store) CreateInvitation(ctx context.Context, inv *models.Invitation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.invitations {
+		if existing.TokenHash == inv.TokenHash {
+			return store.ErrAlreadyExists
+		}
+	}
+	copied := *inv
+	s.invitations[copied.ID] = &copied
+	return nil
+}
+
+// ListInvitations returns the organization's unaccepted invitations, newest first
+func (s *Store) ListInvitations(ctx context.Context, orgID string) ([]models.Invitation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	invitations := []models.Invitation{}
+	for _, inv := range s.invitations {
+		if inv.OrganizationID == orgID && inv.AcceptedAt == nil {
+			invitations = append(invitations, *inv)
+		}
+	}
+	sort.Slice(invitations, func(i, j int) bool {
+		if !invitations[i].CreatedAt.Equal(invitations[j].CreatedAt) {
+			return invitations[i].CreatedAt.After(invitations[j].CreatedAt)
+		}
+		return invitations[i].ID > invitations[j].ID
+	})
+	return invitations, nil
+}
+
+// GetInvitationByHash returns a copy of the invitation with the token hash
+func (s *Store) GetInvitationByHash(ctx context.Context, hash string) (*models.Invitation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, inv := range s.invitations {
+		if inv.TokenHash == hash {
+			copied := *inv
+			return &copied, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+// AcceptInvitation marks the invitation accepted and stores a copy of member
+func (s *Store) AcceptInvitation(ctx context.Context, invitationID string, member *models.Membership) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inv, ok := s.invitations[invitationID]
+	if !ok || inv.AcceptedAt != nil {
+		return store.ErrConflict
+	}
+	id := memberID{member.OrganizationID, member.UserID}
+	if _, ok := s.members[id]; ok {
+		return store.ErrAlreadyExists
+	}
+
+	at := member.CreatedAt
+	inv.AcceptedAt = &at
+	copied := *member
+	s.members[id] = &copied
+	return nil
+}
+
+// addOrganization stores copies of org and its owner. The caller must hold the write lock.
+func (s *Store) addOrganization(org *models.Organization, owner *models.Membership) {
+	o := *org
+	s.organizations[o.ID] = &o
+	m := *owner
+	s.members[memberID{m.OrganizationID, m.UserID}] = &m
+}
+
+// sortMemberships orders memberships oldest first, breaking ties with key
+func sortMemberships(memberships []models.Membership, key func(*models.Membership) string) {
+	sort.Slice(memberships, func(i, j int) bool {
+		if !memberships[i].CreatedAt.Equal(memberships[j].CreatedAt) {
+			return memberships[i].CreatedAt.Before(memberships[j].CreatedAt)
+		}
+		return key(&memberships[i]) < key(&memberships[j])
+	})
+}
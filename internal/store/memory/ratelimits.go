@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"transaction-logger/internal/ratelimit"
+)
+
+// TakeRateLimitToken takes a token from key's bucket
+func (s *Store) TakeRateLimitToken(ctx context.Context, key string, limit ratelimit.Limit, at time.Time) (ratelimit.Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.rateLimits[key]
+	if !ok {
+		b := ratelimit.NewBucket(limit, at)
+		bucket = &b
+		s.rateLimits[key] = bucket
+	}
+	return bucket.Take(limit, at), nil
+}
+
+// PurgeRateLimits deletes buckets last used before the given time
+func (s *Store) PurgeRateLimits(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for key, bucket := range s.rateLimits {
+		if bucket.UpdatedAt.Before(before) {
+			delete(s.rateLimits, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
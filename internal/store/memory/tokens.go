@@ -0,0 +1,163 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+)
+
+// CreateRefreshToken stores a copy of token
+func (s *Store) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.refreshTokens[token.TokenHash]; ok {
+		return store.ErrAlreadyExists
+	}
+	t := *token
+	s.refreshTokens[t.TokenHash] = &t
+	return nil
+}
+
+// GetRefreshToken returns a copy of the token with the hash
+func (s *Store) GetRefreshToken(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token, ok := s.refreshTokens[hash]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	t := *token
+	return &t, nil
+}
+
+// RotateRefreshToken marks the token used and stores next
+func (s *Store) RotateRefreshToken(ctx context.Context, id string, next *models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.refreshTokens {
+		if token.ID != id {
+			continue
+		}
+		if token.UsedAt != nil || token.RevokedAt != nil {
+			return store.ErrConflict
+		}
+		usedAt := next.CreatedAt
+		token.UsedAt = &usedAt
+
+		t := *next
+		s.refreshTokens[t.TokenHash] = &t
+		return nil
+	}
+	return store.ErrNotFound
+}
+
+// RevokeTokenFamily revokes every unrevoked token in the family
+func (s *Store) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, token := range s.refreshTokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// RevokeUserRefreshTokens revokes every unrevoked refresh token the user holds
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, token := range s.refreshTokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// RevokeAccessToken adds an access token ID to the denylist
+func (s *Store) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revokedJTIs[jti] = expiresAt
+	return nil
+}
+
+// IsAccessTokenRevoked reports whether an access token ID is on the denylist
+func (s *Store) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.revokedJTIs[jti]
+	return ok, nil
+}
+
+// CreateUserToken stores a copy of token and deletes the user's unused tokens for the same purpose
+func (s *Store) CreateUserToken(ctx context.Context, token *models.UserToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.userTokens[token.TokenHash]; ok {
+		return store.ErrAlreadyExists
+	}
+	for hash, existing := range s.userTokens {
+		if existing.UserID == token.UserID && existing.Purpose == token.Purpose && existing.UsedAt == nil {
+			delete(s.userTokens, hash)
+		}
+	}
+	t := *token
+	s.userTokens[t.TokenHash] = &t
+	return nil
+}
+
+// ConsumeUserToken marks a usable token used and returns a copy of it
+func (s *Store) ConsumeUserToken(ctx context.Context, hash, purpose string, at time.Time) (*models.UserToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.userTokens[hash]
+	if !ok || token.Purpose != purpose || !token.Usable(at) {
+		return nil, store.ErrNotFound
+	}
+	token.UsedAt = &at
+	t := *token
+	return &t, nil
+}
+
+// PurgeExpiredTokens deletes refresh tokens, denylist entries and user tokens past their expiry
+func (s *Store) PurgeExpiredTokens(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var purged int64
+	for hash, token := range s.refreshTokens {
+		if token.Expired(now) {
+			delete(s.refreshTokens, hash)
+			purged++
+		}
+	}
+	for jti, expiresAt := range s.revokedJTIs {
+		if !now.Before(expiresAt) {
+			delete(s.revokedJTIs, jti)
+			purged++
+		}
+	}
+	for hash, token := range s.userTokens {
+		if !now.Before(token.ExpiresAt) {
+			delete(s.userTokens, hash)
+			purged++
+		}
+	}
+	return purged, nil
+}
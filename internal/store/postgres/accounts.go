@@ -0,0 +1,179 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"transaction-logger/internal/models"
+)
+
+// CreateAccount inserts a new account
+func (s *Store) CreateAccount(ctx context.Context, account *models.Account) error {
+	return insertAccount(ctx, s.db, account, false)
+}
+
+// GetAccount retrieves an account in the organization
+func (s *Store) GetAccount(ctx context.Context, orgID, id string) (*models.Account, error) {
+	return getAccount(ctx, s.db, orgID, id)
+}
+
+// ListAccounts returns all of the organization's accounts, oldest first
+func (s *Store) ListAccounts(ctx context.Context, orgID string) ([]models.Account, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+accountColumns+" FROM accounts WHERE organization_id = $1 ORDER BY created_at, id",
+		orgID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []models.Account{}
+	for rows.Next() {
+		var a models.Account
+		if err := scanAccount(rows, &a); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+// AccountBalance sums the account's ledger entries posted at or before asOf
+func (s *Store) AccountBalance(ctx context.Context, account *models.Account, asOf time.Time) (*models.Balance, error) {
+	balance := &models.Balance{AccountID: account.ID, Currency: account.Currency, AsOf: asOf}
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COALESCE(SUM(amount), 0) FROM ledger_entries WHERE account_id = $1 AND posted_at <= $2",
+		account.ID, asOf,
+	).Scan(&balance.Balance)
+	if err != nil {
+		return nil, err
+	}
+	return balance, nil
+}
+
+// accountColumns is the column list read by scanAccount
+const accountColumns = "id, organization_id, user_id, name, currency, type, created_at"
+
+func scanAccount(row scanner, a *models.Account) error {
+	return row.Scan(&a.ID, &a.OrganizationID, &a.UserID, &a.Name, &a.Currency, &a.Type, &a.CreatedAt)
+}
+
+func insertAccount(ctx context.Context, db dbtx, a *models.Account, ignoreExisting bool) error {
+	query := "INSERT INTO accounts (" + accountColumns + ") VALUES ($1, $2, $3, $4, $5, $6, $7)"
+	if ignoreExisting {
+		// Also covers the one-external-account-per-currency index, whose rows may
+		// predate organizations and so carry a different ID
+		query += " ON CONFLICT DO NOTHING"
+	}
+	_, err := db.ExecContext(ctx, query, a.ID, a.OrganizationID, a.UserID, a.Name, a.Currency, a.Type, a.CreatedAt)
+	return err
+}
+
+func getAccount(ctx context.Context, db dbtx, orgID, id string) (*models.Account, error) {
+	account := &models.Account{}
+	err := scanAccount(db.QueryRowContext(ctx,
+		"SELECT "+accountColumns+" FROM accounts WHERE id = $1 AND organization_id = $2",
+		id, orgID,
+	), account)
+
+	if err == sql.ErrNoRows {
+		return nil, models.ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// postTransaction writes balanced ledger entries for t. The sender is credited and the
+// receiver debited; a side that does not name one of the organization's accounts is
+// posted to the organization's external account for the currency. If neither side
+// names an account, or t's status does not count towards balances, nothing is posted.
+func postTransaction(ctx context.Context, db dbtx, t *models.Transaction) error {
+	sender, err := ledgerAccount(ctx, db, t.OrganizationID, t.SenderAccount, t.Currency)
+	if err != nil {
+		return err
+	}
+	receiver, err := ledgerAccount(ctx, db, t.OrganizationID, t.ReceiverAccount, t.Currency)
+	if err != nil {
+		return err
+	}
+	if (sender == "" && receiver == "") || !models.StatusPosts(t.Status) {
+		return nil
+	}
+
+	for _, side := range []*string{&sender, &receiver} {
+		if *side != "" {
+			continue
+		}
+		external, err := externalAccount(ctx, db, t)
+		if err != nil {
+			return err
+		}
+		*side = external
+	}
+
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO ledger_entries (transaction_id, account_id, amount, posted_at)
+		VALUES ($1, $2, $3, $5), ($1, $4, $6, $5)`,
+		t.ID, sender, -t.Amount, receiver, t.Timestamp, t.Amount,
+	)
+	return err
+}
+
+// checkLedgerAccounts reports whether t could be posted, without writing anything
+func checkLedgerAccounts(ctx context.Context, db dbtx, t *models.Transaction) error {
+	if _, err := ledgerAccount(ctx, db, t.OrganizationID, t.SenderAccount, t.Currency); err != nil {
+		return err
+	}
+	_, err := ledgerAccount(ctx, db, t.OrganizationID, t.ReceiverAccount, t.Currency)
+	return err
+}
+
+// reverseTransaction posts entries offsetting everything previously posted for the transaction
+func reverseTransaction(ctx context.Context, db dbtx, transactionID string, at time.Time) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO ledger_entries (transaction_id, account_id, amount, posted_at)
+		SELECT transaction_id, account_id, -SUM(amount), $2
+		FROM ledger_entries WHERE transaction_id = $1
+		GROUP BY transaction_id, account_id
+		HAVING SUM(amount) <> 0`,
+		transactionID, at,
+	)
+	return err
+}
+
+// externalAccount returns the ID of the organization's external account for t's
+// currency, creating the account if it does not exist yet
+func externalAccount(ctx context.Context, db dbtx, t *models.Transaction) (string, error) {
+	if err := insertAccount(ctx, db, models.NewExternalAccount(t.OrganizationID, t.UserID, t.Currency), true); err != nil {
+		return "", err
+	}
+
+	var id string
+	err := db.QueryRowContext(ctx,
+		"SELECT id FROM accounts WHERE organization_id = $1 AND type = $2 AND currency = $3",
+		t.OrganizationID, models.AccountTypeExternal, t.Currency,
+	).Scan(&id)
+	return id, err
+}
+
+// ledgerAccount resolves an account reference on a transaction to one of the
+// organization's accounts, returning "" when the reference is free text
+func ledgerAccount(ctx context.Context, db dbtx, orgID, ref, currency string) (string, error) {
+	account, err := getAccount(ctx, db, orgID, ref)
+	if errors.Is(err, models.ErrAccountNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := account.CheckCurrency(currency); err != nil {
+		return "", err
+	}
+	return account.ID, nil
+}
@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+)
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_at, revoked_at`
+
+// CreateAPIKey inserts an API key
+func (s *Store) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		key.ID, key.UserID, key.Name, key.Prefix, key.KeyHash, pq.Array(key.Scopes), key.ExpiresAt, key.CreatedAt,
+	)
+	if isUniqueViolation(err) {
+		return store.ErrAlreadyExists
+	}
+	return err
+}
+
+// ListAPIKeys returns the user's API keys, newest first
+func (s *Store) ListAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC, id DESC",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// GetAPIKeyByHash retrieves an API key by the hash of its plaintext
+func (s *Store) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx,
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = $1",
+		hash,
+	))
+	if err == sql.ErrNoRows {
+		return nil, store.ErrNotFound
+	}
+	return key, err
+}
+
+// RevokeAPIKey marks one of the user's keys revoked
+func (s *Store) RevokeAPIKey(ctx context.Context, userID, id string, at time.Time) error {
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE api_keys SET revoked_at = $3 WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL",
+		id, userID, at,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// TouchAPIKey sets the key's last-used time
+func (s *Store) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = $2 WHERE id = $1", id, at)
+	return err
+}
+
+func scanAPIKey(row scanner) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, pq.Array(&key.Scopes),
+		&expiresAt, &lastUsedAt, &key.CreatedAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	key.ExpiresAt = nullTime(expiresAt)
+	key.LastUsedAt = nullTime(lastUsedAt)
+	key.RevokedAt = nullTime(revokedAt)
+	return key, nil
+}
+
+// nullTime converts a nullable column to a pointer
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"transaction-logger/internal/models"
+)
+
+const auditColumns = `id, actor_id, api_key_id, organization_id, action, target_type, target_id,
+	ip, user_agent, request_id, changes, metadata, created_at`
+
+// CreateAuditEvent inserts an event; the table rejects updates and deletes
+func (s *Store) CreateAuditEvent(ctx context.Context, e *models.AuditEvent) error {
+	changes, err := nullJSON(e.Changes, len(e.Changes) > 0)
+	if err != nil {
+		return err
+	}
+	metadata, err := nullJSON(e.Metadata, len(e.Metadata) > 0)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO audit_events (`+auditColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		e.ID, e.ActorID, e.APIKeyID, e.OrganizationID, e.Action, e.TargetType, e.TargetID,
+		e.IP, e.UserAgent, e.RequestID, changes, metadata, e.CreatedAt,
+	)
+	return err
+}
+
+// ListAuditEvents returns one page of matching events, newest first
+func (s *Store) ListAuditEvents(ctx context.Context, filter models.AuditFilter, limit, offset int) ([]models.AuditEvent, int, error) {
+	where, args := filter.Where(0)
+
+	var total int
+	if err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM audit_events WHERE TRUE"+where, args...,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limitArg := len(args) + 1
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+auditColumns+`
+		FROM audit_events WHERE TRUE`+where+` ORDER BY created_at DESC, id DESC`+
+			fmt.Sprintf(" LIMIT $%d OFFSET $%d", limitArg, limitArg+1),
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		var e models.AuditEvent
+		var changes, metadata []byte
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.APIKeyID, &e.OrganizationID, &e.Action, &e.TargetType, &e.TargetID,
+			&e.IP, &e.UserAgent, &e.RequestID, &changes, &metadata, &e.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		if changes != nil {
+			if err := json.Unmarshal(changes, &e.Changes); err != nil {
+				return nil, 0, err
+			}
+		}
+		if metadata != nil {
+			if err := json.Unmarshal(metadata, &e.Metadata); err != nil {
+				return nil, 0, err
+			}
+		}
+		events = append(events, e)
+	}
+	return events, total, rows.Err()
+}
+
+// nullJSON encodes v for a JSONB column, or NULL when present is false
+func nullJSON(v interface{}, present bool) (sql.NullString, error) {
+	if !present {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(v)
+	return sql.NullString{String: string(data), Valid: err == nil}, err
+}
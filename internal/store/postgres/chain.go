@@ -0,0 +1,204 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+)
+
+const checkpointColumns = `id, organization_id, seq, hash, key_id, signature, created_at`
+
+// ListChainLinks returns up to limit of the organization's transactions and status
+// changes after seq. Each table holds its own part of the chain, so the first limit
+// links of both are merged.
+func (s *Store) ListChainLinks(ctx context.Context, orgID string, afterSeq int64, limit int) ([]models.ChainLink, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+transactionColumns+` FROM transactions
+		WHERE organization_id = $1 AND chain_seq > $2 ORDER BY chain_seq LIMIT $3`,
+		orgID, afterSeq, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := scanTransactions(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.QueryContext(ctx,
+		`SELECT `+statusChangeColumns+` FROM transaction_status_history
+		WHERE organization_id = $1 AND chain_seq > $2 ORDER BY chain_seq LIMIT $3`,
+		orgID, afterSeq, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := scanStatusChanges(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]models.ChainLink, 0, len(transactions)+len(changes))
+	for len(links) < limit && (len(transactions) > 0 || len(changes) > 0) {
+		if len(changes) == 0 || len(transactions) > 0 && transactions[0].ChainSeq < changes[0].ChainSeq {
+			links = append(links, models.ChainLink{Transaction: &transactions[0]})
+			transactions = transactions[1:]
+		} else {
+			links = append(links, models.ChainLink{StatusChange: &changes[0]})
+			changes = changes[1:]
+		}
+	}
+	return links, nil
+}
+
+// GetChainHead returns the last link of the organization's chain
+func (s *Store) GetChainHead(ctx context.Context, orgID string) (models.ChainHead, error) {
+	head := models.ChainHead{OrganizationID: orgID}
+	err := s.db.QueryRowContext(ctx,
+		"SELECT seq, hash FROM transaction_chain_heads WHERE organization_id = $1",
+		orgID,
+	).Scan(&head.Seq, &head.Hash)
+	if err == sql.ErrNoRows {
+		return head, nil
+	}
+	return head, err
+}
+
+// ListChainHeads returns the last link of every non-empty chain, ordered by organization
+func (s *Store) ListChainHeads(ctx context.Context) ([]models.ChainHead, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT organization_id, seq, hash FROM transaction_chain_heads WHERE seq > 0 ORDER BY organization_id",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	heads := []models.ChainHead{}
+	for rows.Next() {
+		var head models.ChainHead
+		if err := rows.Scan(&head.OrganizationID, &head.Seq, &head.Hash); err != nil {
+			return nil, err
+		}
+		heads = append(heads, head)
+	}
+	return heads, rows.Err()
+}
+
+// LinkTransactions links unlinked transactions and their current status, oldest first, in one database transaction
+func (s *Store) LinkTransactions(ctx context.Context) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+transactionColumns+` FROM transactions WHERE hash IS NULL
+		ORDER BY organization_id, timestamp, id`,
+	)
+	if err != nil {
+		return 0, err
+	}
+	unlinked, err := scanTransactions(rows)
+	if err != nil {
+		return 0, err
+	}
+
+	heads := map[string]models.ChainHead{}
+	now := time.Now()
+	for j := range unlinked {
+		t := &unlinked[j]
+		head, ok := heads[t.OrganizationID]
+		if !ok {
+			if head, err = lockChainHead(ctx, tx, t.OrganizationID); err != nil {
+				return 0, err
+			}
+		}
+		initial := t.InitialStatus(now)
+		heads[t.OrganizationID] = initial.Link(t.Link(head))
+
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE transactions SET chain_seq = $2, prev_hash = $3, hash = $4 WHERE id = $1",
+			t.ID, t.ChainSeq, t.PrevHash, t.Hash,
+		); err != nil {
+			return 0, err
+		}
+		if err := insertStatusChange(ctx, tx, &initial); err != nil {
+			return 0, err
+		}
+	}
+	for _, head := range heads {
+		if err := saveChainHead(ctx, tx, head); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(unlinked)), tx.Commit()
+}
+
+// CreateChainCheckpoint inserts a signed checkpoint
+func (s *Store) CreateChainCheckpoint(ctx context.Context, cp *models.ChainCheckpoint) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO chain_checkpoints (`+checkpointColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		cp.ID, cp.OrganizationID, cp.Seq, cp.Hash, cp.KeyID, cp.Signature, cp.CreatedAt,
+	)
+	if isUniqueViolation(err) {
+		return store.ErrAlreadyExists
+	}
+	return err
+}
+
+// ListChainCheckpoints returns the organization's checkpoints, newest first
+func (s *Store) ListChainCheckpoints(ctx context.Context, orgID string, limit int) ([]models.ChainCheckpoint, error) {
+	query := `SELECT ` + checkpointColumns + ` FROM chain_checkpoints WHERE organization_id = $1 ORDER BY seq DESC`
+	args := []interface{}{orgID}
+	if limit > 0 {
+		query += " LIMIT $2"
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checkpoints := []models.ChainCheckpoint{}
+	for rows.Next() {
+		var cp models.ChainCheckpoint
+		if err := rows.Scan(&cp.ID, &cp.OrganizationID, &cp.Seq, &cp.Hash, &cp.KeyID, &cp.Signature, &cp.CreatedAt); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints, rows.Err()
+}
+
+// lockChainHead returns the organization's chain head, locking it until the database
+// transaction ends so concurrent inserts are linked one after another
+func lockChainHead(ctx context.Context, db dbtx, orgID string) (models.ChainHead, error) {
+	if _, err := db.ExecContext(ctx,
+		"INSERT INTO transaction_chain_heads (organization_id, seq, hash) VALUES ($1, 0, '') ON CONFLICT (organization_id) DO NOTHING",
+		orgID,
+	); err != nil {
+		return models.ChainHead{}, err
+	}
+
+	head := models.ChainHead{OrganizationID: orgID}
+	err := db.QueryRowContext(ctx,
+		"SELECT seq, hash FROM transaction_chain_heads WHERE organization_id = $1 FOR UPDATE",
+		orgID,
+	).Scan(&head.Seq, &head.Hash)
+	return head, err
+}
+
+func saveChainHead(ctx context.Context, db dbtx, head models.ChainHead) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE transaction_chain_heads SET seq = $2, hash = $3 WHERE organization_id = $1",
+		head.OrganizationID, head.Seq, head.Hash,
+	)
+	return err
+}
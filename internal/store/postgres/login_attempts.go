@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RecordLoginFailure counts a failure for key in a single upsert, so concurrent
+// failures on different instances are all counted
+func (s *Store) RecordLoginFailure(ctx context.Context, key string, at, resetBefore time.Time) (int, error) {
+	var failures int
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO login_attempts (key, failures, last_failure_at) VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failure_at < $3 THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure_at = $2
+		RETURNING failures`,
+		key, at, resetBefore,
+	).Scan(&failures)
+	return failures, err
+}
+
+// LockLogin blocks key until the given time, keeping any later lock
+func (s *Store) LockLogin(ctx context.Context, key string, until time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE login_attempts SET locked_until = GREATEST(COALESCE(locked_until, $2), $2) WHERE key = $1",
+		key, until,
+	)
+	return err
+}
+
+// LoginLockedUntil returns the latest lock on any of keys still in force
+func (s *Store) LoginLockedUntil(ctx context.Context, keys []string, at time.Time) (time.Time, error) {
+	var until sql.NullTime
+	err := s.db.QueryRowContext(ctx,
+		"SELECT MAX(locked_until) FROM login_attempts WHERE key = ANY($1) AND locked_until > $2",
+		pq.Array(keys), at,
+	).Scan(&until)
+	return until.Time, err
+}
+
+// ClearLoginFailures deletes key's record
+func (s *Store) ClearLoginFailures(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM login_attempts WHERE key = $1", key)
+	return err
+}
+
+// PurgeLoginAttempts deletes stale records whose lock has ended
+func (s *Store) PurgeLoginAttempts(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx,
+		"DELETE FROM login_attempts WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $2)",
+		before, time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,127 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"transaction-logger/internal/store"
+)
+
+// SetTOTPSecret stores a pending TOTP secret unless TOTP is already enabled
+func (s *Store) SetTOTPSecret(ctx context.Context, userID, secret string) error {
+	err := s.execOne(ctx,
+		"UPDATE users SET totp_secret = $2, updated_at = $3 WHERE id = $1 AND totp_enabled_at IS NULL",
+		userID, secret, time.Now(),
+	)
+	if err == store.ErrNotFound {
+		return store.ErrConflict
+	}
+	return err
+}
+
+// EnableTOTP enables the pending secret and replaces the recovery codes in one database transaction
+func (s *Store) EnableTOTP(ctx context.Context, userID string, at time.Time, recoveryCodeHashes []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE users SET totp_enabled_at = $2, totp_last_step = 0, updated_at = $2
+		WHERE id = $1 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL`,
+		userID, at,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrConflict
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DisableTOTP clears the TOTP secret and deletes the recovery codes in one database transaction
+func (s *Store) DisableTOTP(ctx context.Context, userID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0, updated_at = $2
+		WHERE id = $1`,
+		userID, time.Now(),
+	); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseTOTPStep advances the user's last accepted step, refusing to move it backwards or repeat it
+func (s *Store) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	err := s.execOne(ctx,
+		"UPDATE users SET totp_last_step = $2 WHERE id = $1 AND totp_last_step < $2",
+		userID, step,
+	)
+	if err == store.ErrNotFound {
+		return store.ErrConflict
+	}
+	return err
+}
+
+// ReplaceRecoveryCodes discards the user's recovery codes and inserts new ones
+func (s *Store) ReplaceRecoveryCodes(ctx context.Context, userID string, hashes []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, hashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ConsumeRecoveryCode marks an unused recovery code used
+func (s *Store) ConsumeRecoveryCode(ctx context.Context, userID, hash string, at time.Time) error {
+	return s.execOne(ctx,
+		"UPDATE recovery_codes SET used_at = $3 WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL",
+		userID, hash, at,
+	)
+}
+
+// CountRecoveryCodes returns how many unused recovery codes the user has left
+func (s *Store) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL",
+		userID,
+	).Scan(&n)
+	return n, err
+}
+
+func replaceRecoveryCodes(ctx context.Context, db dbtx, userID string, hashes []string) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, hash := range hashes {
+		if _, err := db.ExecContext(ctx,
+			"INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, $3)",
+			userID, hash, now,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
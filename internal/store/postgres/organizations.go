@@ -0,0 +1,218 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+)
+
+const invitationColumns = `id, organization_id, email, role, token_hash, invited_by, expires_at, accepted_at, created_at`
+
+// CreateOrganization inserts an organization and its owner in one database transaction
+func (s *Store) CreateOrganization(ctx context.Context, org *models.Organization, owner *models.Membership) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertOrganization(ctx, tx, org); err != nil {
+		return err
+	}
+	if err := insertMembership(ctx, tx, owner); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetMembership retrieves the user's membership of the organization
+func (s *Store) GetMembership(ctx context.Context, orgID, userID string) (*models.Membership, error) {
+	m := &models.Membership{}
+	err := s.db.QueryRowContext(ctx,
+		`SELECT m.organization_id, o.name, m.user_id, m.role, m.created_at
+		FROM organization_members m JOIN organizations o ON o.id = m.organization_id
+		WHERE m.organization_id = $1 AND m.user_id = $2`,
+		orgID, userID,
+	).Scan(&m.OrganizationID, &m.OrganizationName, &m.UserID, &m.Role, &m.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ListMemberships returns the organizations the user belongs to, oldest membership first
+func (s *Store) ListMemberships(ctx context.Context, userID string) ([]models.Membership, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT m.organization_id, o.name, m.user_id, m.role, m.created_at
+		FROM organization_members m JOIN organizations o ON o.id = m.organization_id
+		WHERE m.user_id = $1 ORDER BY m.created_at, m.organization_id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memberships := []models.Membership{}
+	for rows.Next() {
+		var m models.Membership
+		if err := rows.Scan(&m.OrganizationID, &m.OrganizationName, &m.UserID, &m.Role, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, m)
+	}
+	return memberships, rows.Err()
+}
+
+// ListMembers returns the organization's members, oldest first
+func (s *Store) ListMembers(ctx context.Context, orgID string) ([]models.Membership, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT m.organization_id, m.user_id, u.email, m.role, m.created_at
+		FROM organization_members m JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = $1 ORDER BY m.created_at, m.user_id`,
+		orgID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []models.Membership{}
+	for rows.Next() {
+		var m models.Membership
+		if err := rows.Scan(&m.OrganizationID, &m.UserID, &m.Email, &m.Role, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// SetMemberRole changes a member's role
+func (s *Store) SetMemberRole(ctx context.Context, orgID, userID, role string) error {
+	return s.execOne(ctx,
+		"UPDATE organization_members SET role = $3 WHERE organization_id = $1 AND user_id = $2",
+		orgID, userID, role,
+	)
+}
+
+// RemoveMember deletes a membership
+func (s *Store) RemoveMember(ctx context.Context, orgID, userID string) error {
+	return s.execOne(ctx,
+		"DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2",
+		orgID, userID,
+	)
+}
+
+// CreateInvitation inserts an invitation
+func (s *Store) CreateInvitation(ctx context.Context, inv *models.Invitation) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO organization_invitations (id, organization_id, email, role, token_hash, invited_by, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		inv.ID, inv.OrganizationID, inv.Email, inv.Role, inv.TokenHash, inv.InvitedBy, inv.ExpiresAt, inv.CreatedAt,
+	)
+	if isUniqueViolation(err) {
+		return store.ErrAlreadyExists
+	}
+	return err
+}
+
+// ListInvitations returns the organization's unaccepted invitations, newest first
+func (s *Store) ListInvitations(ctx context.Context, orgID string) ([]models.Invitation, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+invitationColumns+` FROM organization_invitations
+		WHERE organization_id = $1 AND accepted_at IS NULL ORDER BY created_at DESC, id DESC`,
+		orgID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []models.Invitation{}
+	for rows.Next() {
+		var inv models.Invitation
+		if err := scanInvitation(rows, &inv); err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, inv)
+	}
+	return invitations, rows.Err()
+}
+
+// GetInvitationByHash retrieves an invitation by the hash of its token
+func (s *Store) GetInvitationByHash(ctx context.Context, hash string) (*models.Invitation, error) {
+	inv := &models.Invitation{}
+	err := scanInvitation(s.db.QueryRowContext(ctx,
+		"SELECT "+invitationColumns+" FROM organization_invitations WHERE token_hash = $1",
+		hash,
+	), inv)
+	if err == sql.ErrNoRows {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// AcceptInvitation marks the invitation accepted and inserts the membership in one database transaction
+func (s *Store) AcceptInvitation(ctx context.Context, invitationID string, member *models.Membership) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Guard on accepted_at so an invitation can only be used once
+	res, err := tx.ExecContext(ctx,
+		"UPDATE organization_invitations SET accepted_at = $2 WHERE id = $1 AND accepted_at IS NULL",
+		invitationID, member.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrConflict
+	}
+
+	if err := insertMembership(ctx, tx, member); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertOrganization(ctx context.Context, db dbtx, org *models.Organization) error {
+	_, err := db.ExecContext(ctx,
+		"INSERT INTO organizations (id, name, created_at) VALUES ($1, $2, $3)",
+		org.ID, org.Name, org.CreatedAt,
+	)
+	if isUniqueViolation(err) {
+		return store.ErrAlreadyExists
+	}
+	return err
+}
+
+func insertMembership(ctx context.Context, db dbtx, m *models.Membership) error {
+	_, err := db.ExecContext(ctx,
+		"INSERT INTO organization_members (organization_id, user_id, role, created_at) VALUES ($1, $2, $3, $4)",
+		m.OrganizationID, m.UserID, m.Role, m.CreatedAt,
+	)
+	if isUniqueViolation(err) {
+		return store.ErrAlreadyExists
+	}
+	return err
+}
+
+func scanInvitation(row scanner, inv *models.Invitation) error {
+	var acceptedAt sql.NullTime
+	err := row.Scan(&inv.ID, &inv.OrganizationID, &inv.Email, &inv.Role, &inv.TokenHash,
+		&inv.InvitedBy, &inv.ExpiresAt, &acceptedAt, &inv.CreatedAt)
+	inv.AcceptedAt = nullTime(acceptedAt)
+	return err
+}
@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"transaction-logger/internal/ratelimit"
+)
+
+// TakeRateLimitToken takes a token from key's bucket. The row is locked for the
+// read-modify-write so instances sharing the database share the limit.
+func (s *Store) TakeRateLimitToken(ctx context.Context, key string, limit ratelimit.Limit, at time.Time) (ratelimit.Result, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	defer tx.Rollback()
+
+	full := ratelimit.NewBucket(limit, at)
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO rate_limit_buckets (key, tokens, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO NOTHING`,
+		key, full.Tokens, full.UpdatedAt,
+	); err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	var bucket ratelimit.Bucket
+	if err := tx.QueryRowContext(ctx,
+		"SELECT tokens, updated_at FROM rate_limit_buckets WHERE key = $1 FOR UPDATE",
+		key,
+	).Scan(&bucket.Tokens, &bucket.UpdatedAt); err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	res := bucket.Take(limit, at)
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3 WHERE key = $1",
+		key, bucket.Tokens, bucket.UpdatedAt,
+	); err != nil {
+		return ratelimit.Result{}, err
+	}
+	return res, tx.Commit()
+}
+
+// PurgeRateLimits deletes buckets last used before the given time
+func (s *Store) PurgeRateLimits(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM rate_limit_buckets WHERE updated_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
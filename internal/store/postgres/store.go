@@ -0,0 +1,39 @@
+// Package postgres implements the store interfaces on PostgreSQL
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"transaction-logger/internal/store"
+)
+
+// Store implements store.Store on a PostgreSQL database migrated with the files in migrations/
+type Store struct {
+	db             *sql.DB
+	idempotencyTTL time.Duration
+}
+
+var _ store.Store = (*Store)(nil)
+
+// New returns a Store using db. Idempotency records older than idempotencyTTL are ignored and purged.
+func New(db *sql.DB, idempotencyTTL time.Duration) *Store {
+	return &Store{db: db, idempotencyTTL: idempotencyTTL}
+}
+
+// dbtx is implemented by both *sql.DB and *sql.Tx
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation"
+}
@@ -0,0 +1,188 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+)
+
+// CreateRefreshToken inserts a refresh token
+func (s *Store) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	return insertRefreshToken(ctx, s.db, token)
+}
+
+// GetRefreshToken retrieves a refresh token by its hash
+func (s *Store) GetRefreshToken(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	token := &models.RefreshToken{}
+	var usedAt, revokedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, user_id, family_id, token_hash, expires_at, created_at, used_at, revoked_at
+		FROM refresh_tokens WHERE token_hash = $1`,
+		hash,
+	).Scan(&token.ID, &token.UserID, &token.FamilyID, &token.TokenHash,
+		&token.ExpiresAt, &token.CreatedAt, &usedAt, &revokedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return token, nil
+}
+
+// RotateRefreshToken marks a token used and inserts its successor in one transaction
+func (s *Store) RotateRefreshToken(ctx context.Context, id string, next *models.RefreshToken) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Guarding on used_at makes concurrent rotations of the same token fail
+	res, err := tx.ExecContext(ctx,
+		"UPDATE refresh_tokens SET used_at = $2 WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL",
+		id, next.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return store.ErrConflict
+	}
+
+	if err := insertRefreshToken(ctx, tx, next); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RevokeTokenFamily revokes every unrevoked token in the family
+func (s *Store) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE refresh_tokens SET revoked_at = $2 WHERE family_id = $1 AND revoked_at IS NULL",
+		familyID, time.Now(),
+	)
+	return err
+}
+
+// RevokeUserRefreshTokens revokes every unrevoked refresh token the user holds
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID string) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE refresh_tokens SET revoked_at = $2 WHERE user_id = $1 AND revoked_at IS NULL",
+		userID, time.Now(),
+	)
+	return err
+}
+
+// RevokeAccessToken adds an access token ID to the denylist
+func (s *Store) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO revoked_access_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING",
+		jti, expiresAt,
+	)
+	return err
+}
+
+// IsAccessTokenRevoked reports whether an access token ID is on the denylist
+func (s *Store) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	err := s.db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM revoked_access_tokens WHERE jti = $1)",
+		jti,
+	).Scan(&revoked)
+	return revoked, err
+}
+
+// CreateUserToken inserts token and deletes the user's unused tokens for the same purpose
+func (s *Store) CreateUserToken(ctx context.Context, token *models.UserToken) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL",
+		token.UserID, token.Purpose,
+	); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO user_tokens (id, user_id, purpose, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		token.ID, token.UserID, token.Purpose, token.TokenHash, token.ExpiresAt, token.CreatedAt,
+	)
+	if isUniqueViolation(err) {
+		return store.ErrAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ConsumeUserToken marks a usable token used in a single statement, so concurrent
+// requests cannot both redeem it
+func (s *Store) ConsumeUserToken(ctx context.Context, hash, purpose string, at time.Time) (*models.UserToken, error) {
+	token := &models.UserToken{}
+	var usedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx,
+		`UPDATE user_tokens SET used_at = $3
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3
+		RETURNING id, user_id, purpose, token_hash, expires_at, used_at, created_at`,
+		hash, purpose, at,
+	).Scan(&token.ID, &token.UserID, &token.Purpose, &token.TokenHash, &token.ExpiresAt, &usedAt, &token.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	token.UsedAt = nullTime(usedAt)
+	return token, nil
+}
+
+// PurgeExpiredTokens deletes refresh tokens, denylist entries and user tokens past their expiry
+func (s *Store) PurgeExpiredTokens(ctx context.Context) (int64, error) {
+	now := time.Now()
+	var purged int64
+	for _, query := range []string{
+		"DELETE FROM refresh_tokens WHERE expires_at < $1",
+		"DELETE FROM revoked_access_tokens WHERE expires_at < $1",
+		"DELETE FROM user_tokens WHERE expires_at < $1",
+	} {
+		res, err := s.db.ExecContext(ctx, query, now)
+		if err != nil {
+			return purged, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return purged, err
+		}
+		purged += n
+	}
+	return purged, nil
+}
+
+func insertRefreshToken(ctx context.Context, db dbtx, token *models.RefreshToken) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		token.ID, token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt, token.CreatedAt,
+	)
+	return err
+}
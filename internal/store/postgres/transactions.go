@@ -0,0 +1,471 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+)
+
+// transactionColumns is the column list read by scanTransaction
+const transactionColumns = `id, timestamp, sender_account, receiver_account,
+	amount, currency, transaction_type, status, organization_id, user_id,
+	chain_seq, prev_hash, hash`
+
+// statusChangeColumns is the column list read by scanStatusChanges
+const statusChangeColumns = `transaction_id, organization_id, user_id, from_status, to_status,
+	reason, created_at, chain_seq, prev_hash, hash`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTransaction(row scanner, t *models.Transaction) error {
+	// Chain columns are NULL until the transaction is linked
+	var chainSeq sql.NullInt64
+	var prevHash, hash sql.NullString
+	err := row.Scan(
+		&t.ID,
+		&t.Timestamp,
+		&t.SenderAccount,
+		&t.ReceiverAccount,
+		&t.Amount,
+		&t.Currency,
+		&t.TransactionType,
+		&t.Status,
+		&t.OrganizationID,
+		&t.UserID,
+		&chainSeq,
+		&prevHash,
+		&hash,
+	)
+	t.ChainSeq, t.PrevHash, t.Hash = chainSeq.Int64, prevHash.String, hash.String
+	return err
+}
+
+func scanTransactions(rows *sql.Rows) ([]models.Transaction, error) {
+	defer rows.Close()
+
+	var transactions []models.Transaction
+	for rows.Next() {
+		var t models.Transaction
+		if err := scanTransaction(rows, &t); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, rows.Err()
+}
+
+func scanStatusChanges(rows *sql.Rows) ([]models.StatusChange, error) {
+	defer rows.Close()
+
+	changes := []models.StatusChange{}
+	for rows.Next() {
+		var c models.StatusChange
+		err := rows.Scan(&c.TransactionID, &c.OrganizationID, &c.UserID, &c.From, &c.To,
+			&c.Reason, &c.At, &c.ChainSeq, &c.PrevHash, &c.Hash)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// ListTransactions returns one page of the organization's matching transactions and the total number of matches
+func (s *Store) ListTransactions(ctx context.Context, orgID string, filter models.TransactionFilter, limit, offset int) ([]models.Transaction, int, error) {
+	where, filterArgs := filter.Where(1)
+	args := append([]interface{}{orgID}, filterArgs...)
+
+	var total int
+	err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM transactions WHERE organization_id = $1`+where,
+		args...,
+	).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	limitArg := len(args) + 1
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+transactionColumns+`
+		FROM transactions WHERE organization_id = $1`+where+filter.OrderBy()+
+			fmt.Sprintf(" LIMIT $%d OFFSET $%d", limitArg, limitArg+1),
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	transactions, err := scanTransactions(rows)
+	return transactions, total, err
+}
+
+// ListTransactionsByCursor returns up to limit matching transactions after the cursor
+func (s *Store) ListTransactionsByCursor(ctx context.Context, orgID string, filter models.TransactionFilter, cursor *models.TransactionCursor, limit int) ([]models.Transaction, error) {
+	where, filterArgs := filter.Where(1)
+	args := append([]interface{}{orgID}, filterArgs...)
+	seek, orderBy, seekArgs := cursor.Seek(filter.SortDesc, len(args))
+	args = append(args, seekArgs...)
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+transactionColumns+`
+		FROM transactions WHERE organization_id = $1`+where+seek+orderBy+
+			fmt.Sprintf(" LIMIT $%d", len(args)+1),
+		append(args, limit)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanTransactions(rows)
+}
+
+// ExportTransactions streams every matching transaction to fn without buffering the result set
+func (s *Store) ExportTransactions(ctx context.Context, orgID string, filter models.TransactionFilter, fn func(*models.Transaction) error) error {
+	where, filterArgs := filter.Where(1)
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+transactionColumns+`
+		FROM transactions WHERE organization_id = $1`+where+filter.OrderBy(),
+		append([]interface{}{orgID}, filterArgs...)...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t models.Transaction
+		if err := scanTransaction(rows, &t); err != nil {
+			return err
+		}
+		if err := fn(&t); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetTransaction loads a transaction by ID, scoped to the given organization
+func (s *Store) GetTransaction(ctx context.Context, orgID, id string) (*models.Transaction, error) {
+	var t models.Transaction
+	err := scanTransaction(s.db.QueryRowContext(ctx,
+		`SELECT `+transactionColumns+`
+		FROM transactions WHERE id = $1 AND organization_id = $2`,
+		id,
+		orgID,
+	), &t)
+	if err == sql.ErrNoRows {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListStatusHistory returns the transaction's status changes, oldest first
+func (s *Store) ListStatusHistory(ctx context.Context, orgID, id string) ([]models.StatusChange, error) {
+	// Distinguish a transaction with no changes yet from one that does not exist
+	if _, err := s.GetTransaction(ctx, orgID, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+statusChangeColumns+` FROM transaction_status_history
+		WHERE transaction_id = $1 AND organization_id = $2 ORDER BY id`,
+		id, orgID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanStatusChanges(rows)
+}
+
+// CreateTransaction inserts t, posts its ledger entries and records idem, all in one database transaction
+func (s *Store) CreateTransaction(ctx context.Context, t *models.Transaction, idem *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if idem != nil {
+		existing, err := s.reserveIdempotencyKey(ctx, tx, idem)
+		if err != nil || existing != nil {
+			return existing, err
+		}
+	}
+
+	head, err := lockChainHead(ctx, tx, t.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	next := t.Link(head)
+	initial := t.InitialStatus(time.Now())
+	next = initial.Link(next)
+	if err := insertTransaction(ctx, tx, t); err != nil {
+		return nil, err
+	}
+	if err := insertStatusChange(ctx, tx, &initial); err != nil {
+		return nil, err
+	}
+	if err := saveChainHead(ctx, tx, next); err != nil {
+		return nil, err
+	}
+
+	// Post balanced ledger entries against any of the organization's accounts
+	if err := postTransaction(ctx, tx, t); err != nil {
+		return nil, err
+	}
+
+	// The replayed response carries the chain fields, which are only known now
+	if idem != nil {
+		if idem.ResponseBody, err = json.Marshal(t); err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(ctx,
+			"UPDATE idempotency_keys SET response_body = $3 WHERE user_id = $1 AND key = $2",
+			idem.UserID, idem.Key, idem.ResponseBody,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, tx.Commit()
+}
+
+// UpdateTransactionStatus applies a status change guarded on the current status
+func (s *Store) UpdateTransactionStatus(ctx context.Context, change models.StatusChange) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := updateTransactionStatus(ctx, tx, change); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// updateTransactionStatus applies change within tx, linking it into the chain and
+// reversing the ledger postings of transactions that did not go through
+func updateTransactionStatus(ctx context.Context, tx *sql.Tx, change models.StatusChange) error {
+	// Lock the chain head before the transaction row, the same order inserts take them in
+	head, err := lockChainHead(ctx, tx, change.OrganizationID)
+	if err != nil {
+		return err
+	}
+
+	// Guard on the current status so concurrent updates cannot both succeed
+	res, err := tx.ExecContext(ctx,
+		`UPDATE transactions SET status = $1
+		WHERE id = $2 AND organization_id = $3 AND status = $4`,
+		change.To, change.TransactionID, change.OrganizationID, change.From,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrConflict
+	}
+
+	next := change.Link(head)
+	if err := insertStatusChange(ctx, tx, &change); err != nil {
+		return err
+	}
+	if err := saveChainHead(ctx, tx, next); err != nil {
+		return err
+	}
+
+	if change.ReversesLedger() {
+		return reverseTransaction(ctx, tx, change.TransactionID, change.At)
+	}
+	return nil
+}
+
+// PurgeIdempotencyKeys deletes keys older than the configured TTL
+func (s *Store) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE created_at < $1", time.Now().Add(-s.idempotencyTTL))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// reserveIdempotencyKey stores idem within tx. It returns nil if the key was free,
+// or the stored record if an earlier request already used it. Expired keys are
+// discarded first. A concurrent request holding the same key blocks here until
+// its transaction finishes.
+func (s *Store) reserveIdempotencyKey(ctx context.Context, tx *sql.Tx, idem *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	_, err := tx.ExecContext(ctx,
+		"DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND created_at < $3",
+		idem.UserID, idem.Key, time.Now().Add(-s.idempotencyTTL),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO idempotency_keys (user_id, key, request_hash, status_code, response_body, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, key) DO NOTHING`,
+		idem.UserID, idem.Key, idem.RequestHash, idem.StatusCode, idem.ResponseBody, idem.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 1 {
+		return nil, nil
+	}
+
+	existing := &models.IdempotencyKey{}
+	var statusCode sql.NullInt64
+	err = tx.QueryRowContext(ctx,
+		`SELECT user_id, key, request_hash, status_code, response_body, created_at
+		FROM idempotency_keys WHERE user_id = $1 AND key = $2`,
+		idem.UserID, idem.Key,
+	).Scan(&existing.UserID, &existing.Key, &existing.RequestHash, &statusCode, &existing.ResponseBody, &existing.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	existing.StatusCode = int(statusCode.Int64)
+
+	return existing, nil
+}
+
+// insertStatusChange records a linked status change in the transaction's history
+func insertStatusChange(ctx context.Context, db dbtx, c *models.StatusChange) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO transaction_status_history (`+statusChangeColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		c.TransactionID, c.OrganizationID, c.UserID, c.From, c.To, c.Reason, c.At,
+		c.ChainSeq, c.PrevHash, c.Hash,
+	)
+	return err
+}
+
+func insertTransaction(ctx context.Context, db dbtx, t *models.Transaction) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO transactions 
+		(id, timestamp, sender_account, receiver_account, amount, currency, transaction_type, status, organization_id, user_id,
+		chain_seq, prev_hash, hash) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		t.ID, t.Timestamp, t.SenderAccount, t.ReceiverAccount, t.Amount, t.Currency, t.TransactionType, t.Status, t.OrganizationID, t.UserID,
+		t.ChainSeq, t.PrevHash, t.Hash,
+	)
+	if isUniqueViolation(err) {
+		return store.ErrAlreadyExists
+	}
+	return err
+}
+
+// BeginImport starts a database transaction for a bulk insert
+func (s *Store) BeginImport(ctx context.Context) (store.TransactionImport, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &transactionImport{tx: tx}, nil
+}
+
+type transactionImport struct {
+	tx *sql.Tx
+}
+
+func (i *transactionImport) Check(ctx context.Context, t *models.Transaction) error {
+	return checkLedgerAccounts(ctx, i.tx, t)
+}
+
+// Insert links the batch onto its chains, writes it with COPY, then posts ledger
+// entries for each transaction
+func (i *transactionImport) Insert(ctx context.Context, transactions []models.Transaction) error {
+	// Each transaction is followed in its chain by its initial history entry
+	heads := map[string]models.ChainHead{}
+	initial := make([]models.StatusChange, len(transactions))
+	now := time.Now()
+	for j := range transactions {
+		t := &transactions[j]
+		head, ok := heads[t.OrganizationID]
+		if !ok {
+			var err error
+			if head, err = lockChainHead(ctx, i.tx, t.OrganizationID); err != nil {
+				return err
+			}
+		}
+		initial[j] = t.InitialStatus(now)
+		heads[t.OrganizationID] = initial[j].Link(t.Link(head))
+	}
+
+	stmt, err := i.tx.PrepareContext(ctx, pq.CopyIn("transactions",
+		"id", "timestamp", "sender_account", "receiver_account",
+		"amount", "currency", "transaction_type", "status", "organization_id", "user_id",
+		"chain_seq", "prev_hash", "hash",
+	))
+	if err != nil {
+		return err
+	}
+
+	for _, t := range transactions {
+		_, err := stmt.ExecContext(ctx,
+			t.ID, t.Timestamp, t.SenderAccount, t.ReceiverAccount,
+			t.Amount.String(), t.Currency, t.TransactionType, t.Status, t.OrganizationID, t.UserID,
+			t.ChainSeq, t.PrevHash, t.Hash,
+		)
+		if err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+
+	// An Exec with no arguments flushes the buffered rows
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return err
+	}
+	// The COPY must be closed before other statements can run on the connection
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+
+	for j := range transactions {
+		if err := insertStatusChange(ctx, i.tx, &initial[j]); err != nil {
+			return err
+		}
+		if err := postTransaction(ctx, i.tx, &transactions[j]); err != nil {
+			return err
+		}
+	}
+	for _, head := range heads {
+		if err := saveChainHead(ctx, i.tx, head); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *transactionImport) Transition(ctx context.Context, change models.StatusChange) error {
+	return updateTransactionStatus(ctx, i.tx, change)
+}
+
+func (i *transactionImport) Commit() error {
+	return i.tx.Commit()
+}
+
+func (i *transactionImport) Rollback() error {
+	err := i.tx.Rollback()
+	if err == sql.ErrTxDone {
+		return nil
+	}
+	return err
+}
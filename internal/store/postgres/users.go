@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+)
+
+// userColumns is the column list read by scanUser
+const userColumns = `id, email, password, role, disabled_at, email_verified_at, totp_secret, totp_enabled_at, default_organization_id, created_at, updated_at`
+
+// CreateUser inserts a new user, their personal organization and their owner membership
+func (s *Store) CreateUser(ctx context.Context, user *models.User) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	org := &models.Organization{ID: user.DefaultOrganizationID, Name: models.PersonalOrganizationName, CreatedAt: user.CreatedAt}
+	if err := insertOrganization(ctx, tx, org); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO users (id, email, password, role, default_organization_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		user.ID, user.Email, user.Password, user.Role, user.DefaultOrganizationID, user.CreatedAt, user.UpdatedAt,
+	)
+	if isUniqueViolation(err) {
+		return store.ErrAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+
+	owner := &models.Membership{OrganizationID: org.ID, UserID: user.ID, Role: models.OrgRoleOwner, CreatedAt: user.CreatedAt}
+	if err := insertMembership(ctx, tx, owner); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetUserByEmail retrieves a user by email
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.getUser(ctx, "email", email)
+}
+
+// GetUserByID retrieves a user by ID
+func (s *Store) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	return s.getUser(ctx, "id", id)
+}
+
+func (s *Store) getUser(ctx context.Context, column, value string) (*models.User, error) {
+	user := &models.User{}
+	err := scanUser(s.db.QueryRowContext(ctx,
+		"SELECT "+userColumns+" FROM users WHERE "+column+" = $1",
+		value,
+	), user)
+
+	if err == sql.ErrNoRows {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// ListUsers returns one page of users, oldest first, and the total number of users
+func (s *Store) ListUsers(ctx context.Context, limit, offset int) ([]models.User, int, error) {
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+userColumns+" FROM users ORDER BY created_at, id LIMIT $1 OFFSET $2",
+		limit, offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
+
+// SetUserRole changes a user's role
+func (s *Store) SetUserRole(ctx context.Context, id, role string) error {
+	return s.execOne(ctx, "UPDATE users SET role = $2, updated_at = $3 WHERE id = $1", id, role, time.Now())
+}
+
+// SetUserDisabled sets or clears a user's disabled time
+func (s *Store) SetUserDisabled(ctx context.Context, id string, at *time.Time) error {
+	return s.execOne(ctx, "UPDATE users SET disabled_at = $2, updated_at = $3 WHERE id = $1", id, at, time.Now())
+}
+
+// SetUserPassword replaces a user's password hash
+func (s *Store) SetUserPassword(ctx context.Context, id, passwordHash string) error {
+	return s.execOne(ctx, "UPDATE users SET password = $2, updated_at = $3 WHERE id = $1", id, passwordHash, time.Now())
+}
+
+// SetEmailVerified records when a user's email was verified, keeping an earlier time
+func (s *Store) SetEmailVerified(ctx context.Context, id string, at time.Time) error {
+	return s.execOne(ctx,
+		"UPDATE users SET email_verified_at = COALESCE(email_verified_at, $2), updated_at = $3 WHERE id = $1",
+		id, at, time.Now(),
+	)
+}
+
+// execOne runs a statement that should affect a row, returning ErrNotFound if nothing matched
+func (s *Store) execOne(ctx context.Context, query string, args ...interface{}) error {
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func scanUser(row scanner, user *models.User) error {
+	var disabledAt, verifiedAt, totpEnabledAt sql.NullTime
+	var totpSecret, defaultOrg sql.NullString
+	err := row.Scan(&user.ID, &user.Email, &user.Password, &user.Role, &disabledAt, &verifiedAt,
+		&totpSecret, &totpEnabledAt, &defaultOrg, &user.CreatedAt, &user.UpdatedAt)
+	user.DisabledAt = nullTime(disabledAt)
+	user.EmailVerifiedAt = nullTime(verifiedAt)
+	user.TOTPSecret = totpSecret.String
+	user.TOTPEnabledAt = nullTime(totpEnabledAt)
+	user.DefaultOrganizationID = defaultOrg.String
+	return err
+}
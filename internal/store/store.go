@@ -0,0 +1,258 @@
+// Package store defines the persistence interfaces used by the HTTP handlers.
+// The postgres subpackage implements them against the database and the memory
+// subpackage keeps everything in process for tests and local demos.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/ratelimit"
+)
+
+var (
+	// ErrNotFound is returned when a record does not exist or belongs to another user or organization
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists is returned when a record would violate a uniqueness rule
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrConflict is returned when a record changed between being read and updated
+	ErrConflict = errors.New("conflict")
+)
+
+// Store is the full set of persistence operations
+type Store interface {
+	UserStore
+	TokenStore
+	MFAStore
+	LoginAttemptStore
+	RateLimitStore
+	APIKeyStore
+	TransactionStore
+	ChainStore
+	AccountStore
+	OrganizationStore
+	AuditStore
+}
+
+// UserStore persists users
+type UserStore interface {
+	// CreateUser inserts a user together with their personal organization, whose ID is
+	// user.DefaultOrganizationID, making them its owner. It returns ErrAlreadyExists if
+	// the email is taken.
+	CreateUser(ctx context.Context, user *models.User) error
+	// GetUserByEmail returns ErrNotFound if no user has the email
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	// GetUserByID returns ErrNotFound if no user has the ID
+	GetUserByID(ctx context.Context, id string) (*models.User, error)
+	// ListUsers returns one page of users ordered by creation time and the total number of users
+	ListUsers(ctx context.Context, limit, offset int) ([]models.User, int, error)
+	// SetUserRole returns ErrNotFound if no user has the ID
+	SetUserRole(ctx context.Context, id, role string) error
+	// SetUserDisabled disables the user at the given time, or re-enables them when at is nil.
+	// It returns ErrNotFound if no user has the ID.
+	SetUserDisabled(ctx context.Context, id string, at *time.Time) error
+	// SetUserPassword replaces the user's password hash, returning ErrNotFound if no user has the ID
+	SetUserPassword(ctx context.Context, id, passwordHash string) error
+	// SetEmailVerified records when the user's email was verified, returning ErrNotFound
+	// if no user has the ID. An earlier verification time is kept.
+	SetEmailVerified(ctx context.Context, id string, at time.Time) error
+}
+
+// TokenStore persists refresh tokens, revoked access tokens and emailed user tokens
+type TokenStore interface {
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	// GetRefreshToken looks a token up by its hash, returning ErrNotFound if it is unknown
+	GetRefreshToken(ctx context.Context, hash string) (*models.RefreshToken, error)
+	// RotateRefreshToken marks the token used and stores next in one step. It returns
+	// ErrConflict if the token was already used or revoked.
+	RotateRefreshToken(ctx context.Context, id string, next *models.RefreshToken) error
+	// RevokeTokenFamily revokes every refresh token in the family
+	RevokeTokenFamily(ctx context.Context, familyID string) error
+	// RevokeUserRefreshTokens revokes every refresh token the user holds
+	RevokeUserRefreshTokens(ctx context.Context, userID string) error
+	// RevokeAccessToken denylists an access token ID until it expires
+	RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error)
+	// CreateUserToken stores token, discarding any unused token the user holds for the same purpose
+	CreateUserToken(ctx context.Context, token *models.UserToken) error
+	// ConsumeUserToken marks the token with the hash used and returns it. It returns
+	// ErrNotFound unless the token exists for purpose and is unused and unexpired at the
+	// given time, so each token is redeemed at most once.
+	ConsumeUserToken(ctx context.Context, hash, purpose string, at time.Time) (*models.UserToken, error)
+	// PurgeExpiredTokens deletes expired refresh tokens, denylist entries and user tokens
+	PurgeExpiredTokens(ctx context.Context) (int64, error)
+}
+
+// MFAStore persists TOTP enrollment and recovery codes. The secret itself is read
+// back on models.User.
+type MFAStore interface {
+	// SetTOTPSecret stores a secret awaiting confirmation, replacing any earlier one.
+	// It returns ErrConflict if TOTP is already enabled.
+	SetTOTPSecret(ctx context.Context, userID, secret string) error
+	// EnableTOTP enables the pending secret and replaces the user's recovery codes in one
+	// step. It returns ErrConflict if there is no pending secret.
+	EnableTOTP(ctx context.Context, userID string, at time.Time, recoveryCodeHashes []string) error
+	// DisableTOTP clears the secret and deletes the user's recovery codes
+	DisableTOTP(ctx context.Context, userID string) error
+	// UseTOTPStep records that a code for step was accepted. It returns ErrConflict if
+	// that step or a later one was already used, so each code works once.
+	UseTOTPStep(ctx context.Context, userID string, step int64) error
+	// ReplaceRecoveryCodes discards the user's recovery codes and stores new ones
+	ReplaceRecoveryCodes(ctx context.Context, userID string, hashes []string) error
+	// ConsumeRecoveryCode marks a code used, returning ErrNotFound if the user has no unused code with the hash
+	ConsumeRecoveryCode(ctx context.Context, userID, hash string, at time.Time) error
+	// CountRecoveryCodes returns how many unused recovery codes the user has left
+	CountRecoveryCodes(ctx context.Context, userID string) (int, error)
+}
+
+// LoginAttemptStore counts failed logins per key, such as an email address or IP,
+// so that guessing passwords can be slowed down and locked out across instances
+type LoginAttemptStore interface {
+	// RecordLoginFailure counts a failure for key at the given time and returns the number
+	// of failures in a row. Counting restarts if the previous failure was before resetBefore.
+	RecordLoginFailure(ctx context.Context, key string, at, resetBefore time.Time) (int, error)
+	// LockLogin blocks key until the given time, keeping any later lock already in place
+	LockLogin(ctx context.Context, key string, until time.Time) error
+	// LoginLockedUntil returns the latest lock on any of keys still in force at the given
+	// time, or the zero time if none is
+	LoginLockedUntil(ctx context.Context, keys []string, at time.Time) (time.Time, error)
+	// ClearLoginFailures forgets key's failures and lifts its lock
+	ClearLoginFailures(ctx context.Context, key string) error
+	// PurgeLoginAttempts deletes keys whose last failure was before the given time and whose lock has ended
+	PurgeLoginAttempts(ctx context.Context, before time.Time) (int64, error)
+}
+
+// RateLimitStore keeps the token buckets behind the API rate limits
+type RateLimitStore interface {
+	// TakeRateLimitToken takes a token from key's bucket, creating a full bucket for a new
+	// key. Concurrent calls for the same key must not both take the last token.
+	TakeRateLimitToken(ctx context.Context, key string, limit ratelimit.Limit, at time.Time) (ratelimit.Result, error)
+	// PurgeRateLimits deletes buckets last used before the given time
+	PurgeRateLimits(ctx context.Context, before time.Time) (int64, error)
+}
+
+// APIKeyStore persists API keys
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	// ListAPIKeys returns the user's keys, newest first, including revoked ones
+	ListAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error)
+	// GetAPIKeyByHash returns ErrNotFound if no key has the hash
+	GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	// RevokeAPIKey returns ErrNotFound if the user has no such unrevoked key
+	RevokeAPIKey(ctx context.Context, userID, id string, at time.Time) error
+	// TouchAPIKey records that the key was used at the given time
+	TouchAPIKey(ctx context.Context, id string, at time.Time) error
+}
+
+// TransactionStore persists transactions and their ledger postings. Reads are scoped
+// to an organization; accounts named on a transaction resolve within t.OrganizationID.
+type TransactionStore interface {
+	// ListTransactions returns one page of the organization's matching transactions and the total number of matches
+	ListTransactions(ctx context.Context, orgID string, filter models.TransactionFilter, limit, offset int) ([]models.Transaction, int, error)
+	// ListTransactionsByCursor returns up to limit matching transactions after the cursor, ordered by
+	// timestamp and ID. Pages read with a prev cursor are returned in reverse order.
+	ListTransactionsByCursor(ctx context.Context, orgID string, filter models.TransactionFilter, cursor *models.TransactionCursor, limit int) ([]models.Transaction, error)
+	// ExportTransactions calls fn for every matching transaction in order, stopping at the first error
+	ExportTransactions(ctx context.Context, orgID string, filter models.TransactionFilter, fn func(*models.Transaction) error) error
+	// GetTransaction returns ErrNotFound if the organization has no such transaction
+	GetTransaction(ctx context.Context, orgID, id string) (*models.Transaction, error)
+	// ListStatusHistory returns the transaction's status changes, oldest first
+	ListStatusHistory(ctx context.Context, orgID, id string) ([]models.StatusChange, error)
+	// CreateTransaction inserts t, records its status as the first history entry and posts
+	// its ledger entries unless its status does not count towards balances. If idem is not nil it is
+	// stored atomically with t, with t encoded as JSON as its response body once t is
+	// linked into its chain; when the user already holds an unexpired record for the
+	// same key nothing is inserted and that record is returned instead. A transaction
+	// with t's ID already existing gives ErrAlreadyExists.
+	CreateTransaction(ctx context.Context, t *models.Transaction, idem *models.IdempotencyKey) (*models.IdempotencyKey, error)
+	// UpdateTransactionStatus applies change and records it in the status history, returning
+	// ErrConflict if the transaction is no longer in change.From or is not in
+	// change.OrganizationID, and reverses ledger postings when change requires it
+	UpdateTransactionStatus(ctx context.Context, change models.StatusChange) error
+	// BeginImport starts an atomic bulk insert
+	BeginImport(ctx context.Context) (TransactionImport, error)
+	// PurgeIdempotencyKeys deletes expired idempotency records and returns how many were removed
+	PurgeIdempotencyKeys(ctx context.Context) (int64, error)
+}
+
+// TransactionImport inserts batches of transactions that become visible together on Commit
+type TransactionImport interface {
+	// Check reports a problem that would stop t from being posted, such as
+	// models.ErrCurrencyMismatch, without writing anything
+	Check(ctx context.Context, t *models.Transaction) error
+	// Insert adds a batch of transactions with their initial history entries and posts the
+	// ledger entries of those whose status counts
+	Insert(ctx context.Context, transactions []models.Transaction) error
+	// Transition applies a status change to a transaction inserted earlier in the
+	// batch, as UpdateTransactionStatus would; store.ErrConflict if it is not in change.From
+	Transition(ctx context.Context, change models.StatusChange) error
+	Commit() error
+	// Rollback discards everything inserted; it is a no-op after Commit
+	Rollback() error
+}
+
+// ChainStore reads and maintains the hash chain over each organization's transactions.
+// CreateTransaction and imports link new transactions onto their chain as they are
+// stored, and UpdateTransactionStatus links each status change after them.
+type ChainStore interface {
+	// ListChainLinks returns up to limit of the organization's links after seq, in chain order
+	ListChainLinks(ctx context.Context, orgID string, afterSeq int64, limit int) ([]models.ChainLink, error)
+	// GetChainHead returns the last link of the organization's chain, or a zero head if it is empty
+	GetChainHead(ctx context.Context, orgID string) (models.ChainHead, error)
+	// ListChainHeads returns the last link of every non-empty chain
+	ListChainHeads(ctx context.Context) ([]models.ChainHead, error)
+	// LinkTransactions appends transactions stored before the chain existed to their
+	// organization's chain, oldest first, each followed by its current status as its
+	// initial history entry, and returns how many were linked
+	LinkTransactions(ctx context.Context) (int64, error)
+	// CreateChainCheckpoint stores a signed checkpoint, returning ErrAlreadyExists if the
+	// organization already has one at the same seq
+	CreateChainCheckpoint(ctx context.Context, cp *models.ChainCheckpoint) error
+	// ListChainCheckpoints returns the organization's checkpoints, newest first; limit <= 0 returns all
+	ListChainCheckpoints(ctx context.Context, orgID string, limit int) ([]models.ChainCheckpoint, error)
+}
+
+// AccountStore persists ledger accounts
+type AccountStore interface {
+	CreateAccount(ctx context.Context, account *models.Account) error
+	// GetAccount returns models.ErrAccountNotFound if the organization has no such account
+	GetAccount(ctx context.Context, orgID, id string) (*models.Account, error)
+	ListAccounts(ctx context.Context, orgID string) ([]models.Account, error)
+	// AccountBalance sums the account's ledger entries posted at or before asOf
+	AccountBalance(ctx context.Context, account *models.Account, asOf time.Time) (*models.Balance, error)
+}
+
+// OrganizationStore persists organizations, their members and invitations
+type OrganizationStore interface {
+	// CreateOrganization inserts org with owner as its first member
+	CreateOrganization(ctx context.Context, org *models.Organization, owner *models.Membership) error
+	// GetMembership returns ErrNotFound if the user is not a member of the organization
+	GetMembership(ctx context.Context, orgID, userID string) (*models.Membership, error)
+	// ListMemberships returns the user's memberships with organization names, oldest first
+	ListMemberships(ctx context.Context, userID string) ([]models.Membership, error)
+	// ListMembers returns the organization's memberships with member emails, oldest first
+	ListMembers(ctx context.Context, orgID string) ([]models.Membership, error)
+	// SetMemberRole returns ErrNotFound if the user is not a member of the organization
+	SetMemberRole(ctx context.Context, orgID, userID, role string) error
+	// RemoveMember returns ErrNotFound if the user is not a member of the organization
+	RemoveMember(ctx context.Context, orgID, userID string) error
+	CreateInvitation(ctx context.Context, invitation *models.Invitation) error
+	// ListInvitations returns the organization's invitations that have not been accepted, newest first
+	ListInvitations(ctx context.Context, orgID string) ([]models.Invitation, error)
+	// GetInvitationByHash returns ErrNotFound if no invitation has the token hash
+	GetInvitationByHash(ctx context.Context, hash string) (*models.Invitation, error)
+	// AcceptInvitation marks the invitation accepted and adds member in one step. It returns
+	// ErrConflict if the invitation was already accepted and ErrAlreadyExists if the user
+	// is already a member.
+	AcceptInvitation(ctx context.Context, invitationID string, member *models.Membership) error
+}
+
+// AuditStore keeps the append-only trail of actions taken through the API
+type AuditStore interface {
+	// CreateAuditEvent appends an event. Events are never changed or deleted.
+	CreateAuditEvent(ctx context.Context, e *models.AuditEvent) error
+	// ListAuditEvents returns one page of matching events, newest first, and the total number of matches
+	ListAuditEvents(ctx context.Context, filter models.AuditFilter, limit, offset int) ([]models.AuditEvent, int, error)
+}
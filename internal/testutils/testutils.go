@@ -2,10 +2,14 @@ package testutils
 
 import (
 	"database/sql"
+	"math"
 	"os"
 	"testing"
 
 	_ "github.com/lib/pq"
+
+	"transaction-logger/internal/database"
+	"transaction-logger/migrations"
 )
 
 // TestDB wraps a database connection for testing
@@ -27,18 +31,18 @@ func SetupTestDB(t *testing.T) *TestDB {
 		t.Fatalf("Failed to connect to test database: %v", err)
 	}
 
-	// Initialize database schema
-	if err := initDBSchema(db); err != nil {
-		t.Fatalf("Failed to initialize test database schema: %v", err)
+	// Apply the same migrations the server runs
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
 	}
 
 	t.Cleanup(func() {
-		// Clean up test data
-		_, err := db.Exec(`
-			DROP TABLE IF EXISTS transactions CASCADE;
-			DROP TABLE IF EXISTS users CASCADE;
-		`)
-		if err != nil {
+		// Roll back every migration to leave an empty database
+		if _, err := migrator.Down(math.MaxInt); err != nil {
 			t.Logf("Failed to clean up test database: %v", err)
 		}
 		db.Close()
@@ -47,41 +51,6 @@ func SetupTestDB(t *testing.T) *TestDB {
 	return &TestDB{db}
 }
 
-// initDBSchema initializes the database schema
-func initDBSchema(db *sql.DB) error {
-	// Create users table
-	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			id TEXT PRIMARY KEY,
-			email TEXT UNIQUE NOT NULL,
-			password TEXT NOT NULL,
-			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
-			updated_at TIMESTAMP WITH TIME ZONE NOT NULL
-		);
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Create transactions table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS transactions (
-			id TEXT PRIMARY KEY,
-			user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-			sender_account TEXT NOT NULL,
-			receiver_account TEXT NOT NULL,
-			amount DECIMAL(15, 2) NOT NULL,
-			currency TEXT NOT NULL,
-			transaction_type TEXT NOT NULL,
-			status TEXT NOT NULL,
-			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
-			updated_at TIMESTAMP WITH TIME ZONE NOT NULL
-		);
-	`)
-
-	return err
-}
-
 // CreateTestUser creates a test user and returns the user ID
 func CreateTestUser(t *testing.T, db *TestDB, email, password string) string {
 	t.Helper()
@@ -0,0 +1,21 @@
+-- Create users table
+CREATE TABLE IF NOT EXISTS users (
+    id TEXT PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE,
+    password TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Create transactions table; ownership is added in 002
+CREATE TABLE IF NOT EXISTS transactions (
+    id TEXT PRIMARY KEY,
+    timestamp TIMESTAMP NOT NULL,
+    sender_account TEXT NOT NULL,
+    receiver_account TEXT NOT NULL,
+    amount DECIMAL(15, 2) NOT NULL,
+    currency TEXT NOT NULL,
+    transaction_type TEXT NOT NULL,
+    status TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -1,5 +1,5 @@
 -- Add user_id column to transactions table
-ALTER TABLE transactions ADD COLUMN user_id TEXT NOT NULL REFERENCES users(id);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE;
 
 -- Create an index on user_id for better query performance
 CREATE INDEX IF NOT EXISTS idx_transactions_user_id ON transactions(user_id);
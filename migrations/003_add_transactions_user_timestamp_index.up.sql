@@ -0,0 +1,2 @@
+-- Supports keyset pagination and date-ordered listing per user
+CREATE INDEX IF NOT EXISTS idx_transactions_user_timestamp ON transactions(user_id, timestamp, id);
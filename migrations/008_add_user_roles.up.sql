@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'auditor', 'admin'));
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_accounts_org_external_currency;
+DROP INDEX IF EXISTS idx_accounts_organization_id;
+ALTER TABLE accounts DROP COLUMN IF EXISTS organization_id;
+DROP INDEX IF EXISTS idx_transactions_org_timestamp;
+ALTER TABLE transactions DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE users DROP COLUMN IF EXISTS default_organization_id;
+DROP TABLE IF EXISTS organization_invitations;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
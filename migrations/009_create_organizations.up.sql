@@ -0,0 +1,59 @@
+-- Organizations own transactions and accounts; users reach them through memberships
+CREATE TABLE IF NOT EXISTS organizations (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    organization_id TEXT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('owner', 'admin', 'member', 'viewer')),
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON organization_members(user_id);
+
+CREATE TABLE IF NOT EXISTS organization_invitations (
+    id TEXT PRIMARY KEY,
+    organization_id TEXT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('admin', 'member', 'viewer')),
+    token_hash TEXT NOT NULL UNIQUE,
+    invited_by TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_invitations_org ON organization_invitations(organization_id);
+
+-- Every existing user gets a personal organization holding their data
+ALTER TABLE users ADD COLUMN IF NOT EXISTS default_organization_id TEXT
+    REFERENCES organizations(id) ON DELETE SET NULL;
+
+INSERT INTO organizations (id, name, created_at)
+SELECT 'org_' || id, 'Personal', created_at FROM users
+ON CONFLICT (id) DO NOTHING;
+
+INSERT INTO organization_members (organization_id, user_id, role, created_at)
+SELECT 'org_' || id, id, 'owner', created_at FROM users
+ON CONFLICT DO NOTHING;
+
+UPDATE users SET default_organization_id = 'org_' || id WHERE default_organization_id IS NULL;
+
+-- Transactions and accounts move from the user to the organization; user_id stays as the creator
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS organization_id TEXT REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE transactions SET organization_id = 'org_' || user_id WHERE organization_id IS NULL;
+ALTER TABLE transactions ALTER COLUMN organization_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_transactions_org_timestamp ON transactions(organization_id, timestamp, id);
+
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS organization_id TEXT REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE accounts SET organization_id = 'org_' || user_id WHERE organization_id IS NULL;
+ALTER TABLE accounts ALTER COLUMN organization_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_accounts_organization_id ON accounts(organization_id);
+
+-- One external account per organization and currency
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_org_external_currency
+    ON accounts(organization_id, currency) WHERE type = 'external';
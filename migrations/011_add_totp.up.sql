@@ -0,0 +1,15 @@
+-- TOTP two-factor authentication. totp_secret is set when enrollment starts and
+-- totp_enabled_at once the first code is confirmed; totp_last_step is the time
+-- step of the last accepted code, so a code cannot be replayed.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+-- Single-use recovery codes for users who lose their authenticator; only hashes are stored
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, code_hash)
+);
@@ -0,0 +1,10 @@
+-- Consecutive failed logins per key ("email:<address>" or "ip:<address>"), shared
+-- by every instance so lockouts cannot be dodged by hitting another server
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failure_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_last_failure_at ON login_attempts(last_failure_at);
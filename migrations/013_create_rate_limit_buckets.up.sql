@@ -0,0 +1,9 @@
+-- Token buckets for the API rate limits, keyed by "<route>:<caller>", for
+-- deployments that share limits between instances (RATE_LIMIT_STORE=postgres)
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_updated_at ON rate_limit_buckets(updated_at);
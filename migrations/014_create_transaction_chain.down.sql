@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS chain_checkpoints;
+DROP TABLE IF EXISTS transaction_chain_heads;
+DROP INDEX IF EXISTS idx_transactions_chain;
+ALTER TABLE transactions DROP COLUMN IF EXISTS hash;
+ALTER TABLE transactions DROP COLUMN IF EXISTS prev_hash;
+ALTER TABLE transactions DROP COLUMN IF EXISTS chain_seq;
@@ -0,0 +1,27 @@
+-- Tamper-evident hash chain over each organization's transactions. Each row stores
+-- its position, the previous link's hash and the hash of its own content; rows that
+-- exist before this migration are linked by the server when it starts.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS chain_seq BIGINT;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS prev_hash TEXT;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS hash TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_chain ON transactions(organization_id, chain_seq);
+
+-- The last link of each chain, locked while appending so links are numbered without gaps
+CREATE TABLE IF NOT EXISTS transaction_chain_heads (
+    organization_id TEXT PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+    seq BIGINT NOT NULL,
+    hash TEXT NOT NULL
+);
+
+-- Signed snapshots of chain heads; rewriting history before one no longer matches it
+CREATE TABLE IF NOT EXISTS chain_checkpoints (
+    id TEXT PRIMARY KEY,
+    organization_id TEXT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    seq BIGINT NOT NULL,
+    hash TEXT NOT NULL,
+    key_id TEXT NOT NULL,
+    signature TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (organization_id, seq)
+);
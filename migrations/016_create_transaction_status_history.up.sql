@@ -0,0 +1,19 @@
+-- Every status change a transaction goes through, with who made it and why. Each
+-- change is also a link in its organization's hash chain, numbered alongside the
+-- transactions themselves.
+CREATE TABLE IF NOT EXISTS transaction_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    transaction_id TEXT NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    organization_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    chain_seq BIGINT NOT NULL,
+    prev_hash TEXT NOT NULL,
+    hash TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_status_history_transaction ON transaction_status_history(transaction_id, id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transaction_status_history_chain ON transaction_status_history(organization_id, chain_seq);
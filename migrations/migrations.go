@@ -0,0 +1,10 @@
+// Package migrations embeds the numbered SQL migration files so the server
+// binary can apply them without shipping the directory alongside it.
+package migrations
+
+import "embed"
+
+// FS holds every NNN_name.up.sql and NNN_name.down.sql file in this directory
+//
+//go:embed *.sql
+var FS embed.FS
@@ -1,39 +1,43 @@
 package auth_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
-
 	"transaction-logger/internal/auth"
 	"transaction-logger/internal/config"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGenerateAndValidateJWT(t *testing.T) {
 	// Setup test config
 	cfg := &config.Config{
-		JWTSecret: "test-secret-key-123",
+		JWTSigningAlg: auth.AlgEdDSA,
 	}
-	auth.Init(cfg)
+	require.NoError(t, auth.Init(cfg))
+	key := auth.Keys().Active()
 
 	userID := "test-user-123"
 	email := "test@example.com"
 
 	// Test token generation
-	tokenString, err := auth.GenerateJWT(userID, email)
+	tokenString, err := auth.GenerateJWT(userID, email, "user")
 	assert.NoError(t, err)
 	assert.NotEmpty(t, tokenString)
 
 	// Test token validation
 	t.Run("valid token", func(t *testing.T) {
 		token, err := jwt.ParseWithClaims(tokenString, &auth.Claims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.JWTSecret), nil
+			return key.Private.Public(), nil
 		})
 		assert.NoError(t, err)
-		
+		assert.Equal(t, key.ID, token.Header["kid"])
+
 		if claims, ok := token.Claims.(*auth.Claims); ok && token.Valid {
 			assert.Equal(t, userID, claims.UserID)
 			assert.Equal(t, email, claims.Email)
@@ -45,38 +49,132 @@ func TestGenerateAndValidateJWT(t *testing.T) {
 	// Test expired token
 	t.Run("expired token", func(t *testing.T) {
 		// Create an expired token
-		expiredToken, err := generateTestTokenWithExpiration(userID, email, -time.Hour, cfg.JWTSecret)
+		expiredToken, err := generateTestTokenWithExpiration(userID, email, -time.Hour, key)
 		assert.NoError(t, err)
 
-		_, err = jwt.ParseWithClaims(expiredToken, &auth.Claims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.JWTSecret), nil
-		})
+		_, err = auth.ValidateToken(expiredToken)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "token is expired")
 	})
 
 	// Test invalid signature
 	t.Run("invalid signature", func(t *testing.T) {
-		// Create a token with different secret
-		differentToken, err := generateTestTokenWithExpiration(userID, email, time.Hour, "different-secret-key")
+		// Create a token with a different key that claims the active kid
+		other, err := auth.GenerateKey(auth.AlgEdDSA)
+		require.NoError(t, err)
+		other.ID = key.ID
+
+		differentToken, err := generateTestTokenWithExpiration(userID, email, time.Hour, other)
 		assert.NoError(t, err)
 
-		_, err = jwt.ParseWithClaims(differentToken, &auth.Claims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.JWTSecret), nil
-		})
+		_, err = auth.ValidateToken(differentToken)
 		assert.Error(t, err)
 	})
+
+	// HS256 tokens signed with the public key must not be accepted
+	t.Run("algorithm confusion", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, &auth.Claims{UserID: userID})
+		token.Header["kid"] = key.ID
+		forged, err := token.SignedString([]byte("secret"))
+		require.NoError(t, err)
+
+		_, err = auth.ValidateToken(forged)
+		assert.Error(t, err)
+	})
+}
+
+func TestKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := auth.NewKeySet(dir, auth.AlgRS256, time.Hour)
+	require.NoError(t, err)
+
+	first := ks.Active()
+	assert.FileExists(t, filepath.Join(dir, first.ID+".pem"))
+
+	// Not due yet
+	rotated, err := ks.RotateIfDue(24*time.Hour, time.Now())
+	require.NoError(t, err)
+	assert.False(t, rotated)
+
+	// The old key stays published through the grace period
+	second, err := ks.Rotate(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, ks.Active().ID)
+	_, ok := ks.Lookup(first.ID)
+	assert.True(t, ok)
+	assert.Len(t, ks.JWKS().Keys, 2)
+
+	// A second instance sharing the directory sees both keys
+	loaded, err := auth.LoadKeys(dir)
+	require.NoError(t, err)
+	assert.Len(t, loaded, 2)
+
+	// After the grace period the old key is dropped along with its file
+	_, err = ks.Rotate(time.Now().Add(2 * time.Hour))
+	require.NoError(t, err)
+	_, ok = ks.Lookup(first.ID)
+	assert.False(t, ok)
+	_, err = os.Stat(filepath.Join(dir, first.ID+".pem"))
+	assert.True(t, os.IsNotExist(err))
+
+	jwk := ks.JWKS().Keys[0]
+	assert.Equal(t, "RSA", jwk.KeyType)
+	assert.Equal(t, "RS256", jwk.Algorithm)
+	assert.NotEmpty(t, jwk.N)
+}
+
+func TestLookupReloadsUnknownKeys(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := auth.NewKeySet(dir, auth.AlgEdDSA, time.Hour)
+	require.NoError(t, err)
+	other, err := auth.NewKeySet(dir, auth.AlgEdDSA, time.Hour)
+	require.NoError(t, err)
+
+	// A key rotated in by another instance is found without waiting for a reload
+	rotated, err := other.Rotate(time.Now())
+	require.NoError(t, err)
+	key, ok := ks.Lookup(rotated.ID)
+	require.True(t, ok)
+	assert.Equal(t, rotated.ID, key.ID)
+
+	// Further misses within the interval do not read the directory again
+	again, err := other.Rotate(time.Now())
+	require.NoError(t, err)
+	_, ok = ks.Lookup(again.ID)
+	assert.False(t, ok)
+}
+
+func TestLookupKeepsKeysWhenDirectoryIsEmptied(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := auth.NewKeySet(dir, auth.AlgEdDSA, time.Hour)
+	require.NoError(t, err)
+	active := ks.Active()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	require.NoError(t, err)
+	for _, path := range paths {
+		require.NoError(t, os.Remove(path))
+	}
+
+	// The unknown kid makes Lookup reload the now empty directory
+	_, ok := ks.Lookup("unknown")
+	assert.False(t, ok)
+	assert.Equal(t, active.ID, ks.Active().ID)
+	key, ok := ks.Lookup(active.ID)
+	require.True(t, ok)
+	assert.Equal(t, active.ID, key.ID)
 }
 
 // generateTestTokenWithExpiration is a test helper to generate a token with custom expiration
-func generateTestTokenWithExpiration(userID, email string, expiration time.Duration, secret string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &auth.Claims{
+func generateTestTokenWithExpiration(userID, email string, expiration time.Duration, key *auth.SigningKey) (string, error) {
+	token := jwt.NewWithClaims(key.Method, &auth.Claims{
 		UserID: userID,
 		Email:  email,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiration)),
 		},
 	})
+	token.Header["kid"] = key.ID
 
-	return token.SignedString([]byte(secret))
+	return token.SignedString(key.Private)
 }
@@ -0,0 +1,64 @@
+package auth_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"transaction-logger/internal/auth"
+	"transaction-logger/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTPMatchesRFC6238(t *testing.T) {
+	// The SHA-1 secret from RFC 6238 appendix B, truncated to six digits
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range vectors {
+		code, err := auth.GenerateTOTPCode(secret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, want, code, "at %d", unix)
+	}
+
+	// One step of clock skew is tolerated, two are not
+	at := time.Unix(1111111109, 0)
+	step, ok := auth.ValidateTOTP(secret, "081804", at.Add(30*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, int64(1111111109/30), step)
+	_, ok = auth.ValidateTOTP(secret, "081804", at.Add(time.Minute))
+	assert.False(t, ok)
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	secret, err := auth.GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	uri := auth.TOTPProvisioningURI("Transaction Logger", "user@example.com", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Transaction%20Logger:user@example.com?"), uri)
+	assert.Contains(t, uri, "secret="+secret)
+}
+
+func TestMFAChallengeIsNotAnAccessToken(t *testing.T) {
+	require.NoError(t, auth.Init(&config.Config{}))
+
+	challenge, err := auth.GenerateMFAChallenge("user-1")
+	require.NoError(t, err)
+	_, err = auth.ValidateToken(challenge)
+	assert.Error(t, err)
+
+	claims, err := auth.ValidateMFAChallenge(challenge)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.UserID)
+
+	access, err := auth.GenerateJWT("user-1", "user@example.com", "user")
+	require.NoError(t, err)
+	_, err = auth.ValidateMFAChallenge(access)
+	assert.Error(t, err)
+}
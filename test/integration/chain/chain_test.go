@@ -0,0 +1,255 @@
+package chain_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"transaction-logger/internal/chain"
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+	"transaction-logger/internal/store/memory"
+	"transaction-logger/internal/store/postgres"
+	"transaction-logger/internal/testutils"
+
+	_ "github.com/lib/pq"
+)
+
+// tampered stands in for someone editing rows behind the application's back
+type tampered struct {
+	store.ChainStore
+	edit func(links []models.ChainLink) []models.ChainLink
+}
+
+func (s tampered) ListChainLinks(ctx context.Context, orgID string, afterSeq int64, limit int) ([]models.ChainLink, error) {
+	links, err := s.ChainStore.ListChainLinks(ctx, orgID, afterSeq, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.edit(links), nil
+}
+
+// relink recomputes every hash from links[from] on, as someone covering up an edit would
+func relink(links []models.ChainLink, from int) {
+	_, prevHash := links[from-1].Hashes()
+	for _, link := range links[from:] {
+		if c := link.StatusChange; c != nil {
+			c.PrevHash, c.Hash = prevHash, c.ChainHash(prevHash)
+		} else {
+			t := link.Transaction
+			t.PrevHash, t.Hash = prevHash, t.ChainHash(prevHash)
+		}
+		_, prevHash = link.Hashes()
+	}
+}
+
+func newSigner(t *testing.T) *chain.Signer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return chain.NewSigner(key)
+}
+
+func TestVerifyChain(t *testing.T) {
+	ctx := context.Background()
+	st := memory.New(0)
+	signer := newSigner(t)
+	const org = "org_test"
+
+	for i := 1; i <= 3; i++ {
+		txn := &models.Transaction{
+			ID: fmt.Sprintf("txn_%d", i), Timestamp: time.Now(), SenderAccount: "a", ReceiverAccount: "b",
+			Amount: models.Money(100 * i), Currency: "USD", TransactionType: "Transfer",
+			Status: models.StatusCompleted, OrganizationID: org, UserID: "usr_1",
+		}
+		_, err := st.CreateTransaction(ctx, txn, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2*i-1), txn.ChainSeq, "each transaction is followed by its initial status")
+	}
+
+	report, err := chain.Verify(ctx, st, signer, org)
+	require.NoError(t, err)
+	assert.True(t, report.Valid)
+	assert.Equal(t, int64(6), report.Links)
+
+	created, err := chain.Checkpoint(ctx, st, signer)
+	require.NoError(t, err)
+	assert.Equal(t, 1, created)
+	created, err = chain.Checkpoint(ctx, st, signer)
+	require.NoError(t, err)
+	assert.Zero(t, created, "an unchanged head is not checkpointed again")
+
+	verify := func(edit func([]models.ChainLink) []models.ChainLink) *models.ChainReport {
+		report, err := chain.Verify(ctx, tampered{st, edit}, signer, org)
+		require.NoError(t, err)
+		return report
+	}
+
+	// Editing a row breaks its own hash
+	report = verify(func(links []models.ChainLink) []models.ChainLink {
+		if len(links) > 2 {
+			links[2].Transaction.Amount = 1
+		}
+		return links
+	})
+	assert.False(t, report.Valid)
+	assert.Equal(t, &models.ChainBreak{Seq: 3, TransactionID: "txn_2", Reason: "content does not match its hash"}, report.Broken)
+
+	// Recomputing every hash after the edit is caught by the signed checkpoint
+	report = verify(func(links []models.ChainLink) []models.ChainLink {
+		if len(links) > 2 {
+			links[2].Transaction.Amount = 1
+			relink(links, 2)
+		}
+		return links
+	})
+	require.NotNil(t, report.Broken)
+	assert.Equal(t, int64(6), report.Broken.Seq)
+	assert.Contains(t, report.Broken.Reason, "checkpoint")
+
+	// So are deleted rows, in the middle or at the end
+	report = verify(func(links []models.ChainLink) []models.ChainLink {
+		return append(links[:1:1], links[2:]...)
+	})
+	assert.Equal(t, &models.ChainBreak{Seq: 2, Reason: "link is missing"}, report.Broken)
+	report = verify(func(links []models.ChainLink) []models.ChainLink {
+		return links[:len(links)-1]
+	})
+	require.NotNil(t, report.Broken)
+	assert.Equal(t, int64(6), report.Broken.Seq)
+
+	// Checkpoints from another key are not trusted
+	report, err = chain.Verify(ctx, st, newSigner(t), org)
+	require.NoError(t, err)
+	assert.True(t, report.Valid)
+	assert.Zero(t, report.Checkpoints)
+	assert.Equal(t, 1, report.UnverifiedCheckpoints)
+}
+
+func TestVerifyStatusChanges(t *testing.T) {
+	ctx := context.Background()
+	st := memory.New(0)
+	signer := newSigner(t)
+	const org = "org_test"
+
+	for i := 1; i <= 2; i++ {
+		txn := &models.Transaction{
+			ID: fmt.Sprintf("txn_%d", i), Timestamp: time.Now(), SenderAccount: "a", ReceiverAccount: "b",
+			Amount: 100, Currency: "USD", TransactionType: "Transfer",
+			Status: models.StatusCompleted, OrganizationID: org, UserID: "usr_1",
+		}
+		_, err := st.CreateTransaction(ctx, txn, nil)
+		require.NoError(t, err)
+	}
+	require.NoError(t, st.UpdateTransactionStatus(ctx, models.StatusChange{
+		TransactionID: "txn_1", OrganizationID: org, UserID: "usr_1",
+		From: models.StatusCompleted, To: models.StatusReversed, Reason: "refund", At: time.Now(),
+	}))
+
+	history, err := st.ListStatusHistory(ctx, org, "txn_1")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, models.StatusChange{TransactionID: "txn_1", OrganizationID: org, UserID: "usr_1", To: models.StatusCompleted,
+		At: history[0].At, ChainSeq: 2, PrevHash: history[0].PrevHash, Hash: history[0].Hash}, history[0])
+	assert.Equal(t, int64(5), history[1].ChainSeq)
+
+	report, err := chain.Verify(ctx, st, signer, org)
+	require.NoError(t, err)
+	assert.True(t, report.Valid)
+	assert.Equal(t, int64(5), report.Links)
+
+	verify := func(edit func([]models.ChainLink) []models.ChainLink) *models.ChainReport {
+		report, err := chain.Verify(ctx, tampered{st, edit}, signer, org)
+		require.NoError(t, err)
+		return report
+	}
+
+	// Setting the status directly does not match the chained change
+	report = verify(func(links []models.ChainLink) []models.ChainLink {
+		links[0].Transaction.Status = models.StatusCompleted
+		return links
+	})
+	require.NotNil(t, report.Broken)
+	assert.Equal(t, int64(5), report.Broken.Seq)
+	assert.Equal(t, "txn_1", report.Broken.TransactionID)
+
+	// So does changing the status of a transaction that was never changed
+	report = verify(func(links []models.ChainLink) []models.ChainLink {
+		links[2].Transaction.Status = models.StatusReversed
+		return links
+	})
+	require.NotNil(t, report.Broken)
+	assert.Equal(t, int64(4), report.Broken.Seq)
+	assert.Equal(t, "txn_2", report.Broken.TransactionID)
+
+	// Editing the change breaks its hash
+	report = verify(func(links []models.ChainLink) []models.ChainLink {
+		links[4].StatusChange.Reason = "typo"
+		return links
+	})
+	assert.Equal(t, &models.ChainBreak{Seq: 5, TransactionID: "txn_1", Reason: "content does not match its hash"}, report.Broken)
+
+	// Dropping it leaves the chain short of its head
+	report = verify(func(links []models.ChainLink) []models.ChainLink {
+		return links[:4]
+	})
+	require.NotNil(t, report.Broken)
+	assert.Equal(t, int64(5), report.Broken.Seq)
+
+	// And a change that skips a status is caught even with its hash recomputed
+	report = verify(func(links []models.ChainLink) []models.ChainLink {
+		links[4].StatusChange.From = models.StatusPending
+		relink(links, 4)
+		return links
+	})
+	assert.Equal(t, &models.ChainBreak{Seq: 5, TransactionID: "txn_1", Reason: "status change from Pending does not follow the change to Completed"}, report.Broken)
+}
+
+func TestVerifyPostgresChain(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	st := postgres.New(db.DB, 0)
+	ctx := context.Background()
+
+	user, err := models.NewUser("chain@example.com", "password123")
+	require.NoError(t, err)
+	require.NoError(t, st.CreateUser(ctx, user))
+	org := user.DefaultOrganizationID
+
+	for i := 1; i <= 2; i++ {
+		txn := &models.Transaction{
+			ID: fmt.Sprintf("txn_%d", i), Timestamp: time.Now(), SenderAccount: "a", ReceiverAccount: "b",
+			Amount: models.Money(100 * i), Currency: "USD", TransactionType: "Transfer",
+			Status: models.StatusCompleted, OrganizationID: org, UserID: user.ID,
+		}
+		_, err := st.CreateTransaction(ctx, txn, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2*i-1), txn.ChainSeq)
+	}
+
+	require.NoError(t, st.UpdateTransactionStatus(ctx, models.StatusChange{
+		TransactionID: "txn_1", OrganizationID: org, UserID: user.ID,
+		From: models.StatusCompleted, To: models.StatusReversed, At: time.Now(),
+	}))
+
+	report, err := chain.Verify(ctx, st, newSigner(t), org)
+	require.NoError(t, err)
+	assert.True(t, report.Valid, "%+v", report.Broken)
+	assert.Equal(t, int64(5), report.Links)
+}
+
+func TestChainHashMatchesStoredRow(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 30, 0, 123456789, time.UTC)
+	txn := models.Transaction{ID: "txn_1", Timestamp: at, Amount: 500, Status: models.StatusPending}
+	hash := txn.ChainHash("")
+
+	// The database keeps microseconds and returns wall-clock time
+	txn.Timestamp = time.Date(2026, 3, 1, 12, 30, 0, 123457000, time.FixedZone("", 0))
+	txn.Status = models.StatusCompleted
+	assert.Equal(t, hash, txn.ChainHash(""))
+	assert.NotEqual(t, hash, txn.ChainHash("previous"))
+}
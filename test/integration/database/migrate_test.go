@@ -0,0 +1,64 @@
+package database_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"transaction-logger/internal/database"
+	"transaction-logger/internal/testutils"
+	"transaction-logger/migrations"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	// The embedded migrations must always parse
+	_, err := database.NewMigrator(nil, migrations.FS)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		fs   fstest.MapFS
+	}{
+		{"missing up file", fstest.MapFS{
+			"001_init.down.sql": {Data: []byte("DROP TABLE x;")},
+		}},
+		{"conflicting names", fstest.MapFS{
+			"001_init.up.sql":  {Data: []byte("CREATE TABLE x ();")},
+			"001_other.up.sql": {Data: []byte("CREATE TABLE y ();")},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := database.NewMigrator(nil, tt.fs)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+
+	migrator, err := database.NewMigrator(db.DB, migrations.FS)
+	assert.NoError(t, err)
+
+	// SetupTestDB has already applied everything
+	applied, err := migrator.Up()
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+
+	statuses, err := migrator.Status()
+	assert.NoError(t, err)
+	for _, s := range statuses {
+		assert.NotNil(t, s.AppliedAt, "%03d_%s", s.Version, s.Name)
+	}
+
+	reverted, err := migrator.Down(1)
+	assert.NoError(t, err)
+	assert.Len(t, reverted, 1)
+
+	applied, err = migrator.Up()
+	assert.NoError(t, err)
+	assert.Equal(t, reverted, applied)
+}
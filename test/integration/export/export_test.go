@@ -0,0 +1,104 @@
+package export_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"transaction-logger/internal/export"
+	"transaction-logger/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var sample = models.Transaction{
+	ID:              "TXN1",
+	Timestamp:       time.Date(2025, 5, 23, 18, 57, 45, 0, time.UTC),
+	SenderAccount:   "ACC<1>",
+	ReceiverAccount: "ACC,2",
+	Amount:          15075,
+	Currency:        "USD",
+	TransactionType: "Transfer",
+	Status:          "Completed",
+	UserID:          "usr_1",
+}
+
+func writeAll(t *testing.T, format string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := export.NewWriter(format, &buf)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(&sample))
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestCSV(t *testing.T) {
+	out := string(writeAll(t, export.FormatCSV))
+	assert.Equal(t,
+		"id,timestamp,sender_account,receiver_account,amount,currency,transaction_type,status\n"+
+			"TXN1,2025-05-23T18:57:45Z,ACC<1>,\"ACC,2\",150.75,USD,Transfer,Completed\n",
+		out)
+}
+
+func TestCSVEscapesFormulas(t *testing.T) {
+	for _, account := range []string{"=1+1", "+1", "-1", "@SUM(A1)", "\tx", "\rx"} {
+		tx := sample
+		tx.SenderAccount = account
+
+		var buf bytes.Buffer
+		w, err := export.NewWriter(export.FormatCSV, &buf)
+		require.NoError(t, err)
+		require.NoError(t, w.Write(&tx))
+		require.NoError(t, w.Close())
+
+		rows, err := csv.NewReader(&buf).ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, "'"+account, rows[1][2])
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	out := string(writeAll(t, export.FormatNDJSON))
+	assert.True(t, strings.HasSuffix(out, "}\n"))
+	assert.Contains(t, out, `"amount":"150.75"`)
+	assert.Equal(t, 1, strings.Count(out, "\n"))
+}
+
+func TestXLSX(t *testing.T) {
+	out := writeAll(t, export.FormatXLSX)
+
+	zr, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	require.NoError(t, err)
+
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		b, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		parts[f.Name] = string(b)
+	}
+
+	assert.Contains(t, parts, "[Content_Types].xml")
+	assert.Contains(t, parts, "xl/workbook.xml")
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	assert.Contains(t, sheet, `<c t="inlineStr"><is><t>ACC&lt;1&gt;</t></is></c>`)
+	assert.Contains(t, sheet, `<c t="n"><v>150.75</v></c>`)
+	assert.Equal(t, 2, strings.Count(sheet, "<row>"))
+}
+
+func TestFormatForMediaType(t *testing.T) {
+	assert.Equal(t, export.FormatNDJSON, export.FormatForMediaType("application/x-ndjson"))
+	assert.Equal(t, "", export.FormatForMediaType("application/json"))
+
+	_, err := export.NewWriter("pdf", io.Discard)
+	assert.Error(t, err)
+}
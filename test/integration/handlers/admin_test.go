@@ -0,0 +1,72 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"transaction-logger/internal/auth"
+	"transaction-logger/internal/config"
+	"transaction-logger/internal/handlers"
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store/memory"
+)
+
+func TestAdminRoutes(t *testing.T) {
+	require.NoError(t, auth.Init(&config.Config{}))
+	st := memory.New(0)
+	ctx := context.Background()
+
+	// One user of each role
+	tokens := map[string]string{}
+	ids := map[string]string{}
+	for _, role := range []string{models.RoleUser, models.RoleAuditor, models.RoleAdmin} {
+		user, err := models.NewUser(role+"@example.com", "password123")
+		require.NoError(t, err)
+		user.Role = role
+		require.NoError(t, st.CreateUser(ctx, user))
+
+		// Tokens carry the default role; the middleware reads the current one from the store
+		token, err := auth.GenerateJWT(user.ID, user.Email, models.RoleUser)
+		require.NoError(t, err)
+		tokens[role], ids[role] = token, user.ID
+	}
+
+	admin := handlers.NewAdminHandler(st, st, st, st, nil, nil)
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api").Subrouter()
+	api.Use(handlers.AuthMiddleware(st, st))
+	adminRouter := api.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(handlers.RequirePermission(auth.PermReadUsers))
+	adminRouter.HandleFunc("/users", admin.ListUsers).Methods("GET")
+	adminRouter.Handle("/users/{id}/disable",
+		handlers.RequirePermission(auth.PermManageUsers)(http.HandlerFunc(admin.DisableUser))).Methods("POST")
+
+	call := func(method, path, role string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+tokens[role])
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, call("GET", "/api/admin/users", models.RoleUser))
+	assert.Equal(t, http.StatusOK, call("GET", "/api/admin/users", models.RoleAuditor))
+	assert.Equal(t, http.StatusOK, call("GET", "/api/admin/users", models.RoleAdmin))
+
+	disableUser := "/api/admin/users/" + ids[models.RoleUser] + "/disable"
+	assert.Equal(t, http.StatusForbidden, call("POST", disableUser, models.RoleAuditor))
+	assert.Equal(t, http.StatusOK, call("POST", disableUser, models.RoleAdmin))
+	assert.Equal(t, http.StatusConflict, call("POST", "/api/admin/users/"+ids[models.RoleAdmin]+"/disable", models.RoleAdmin))
+
+	// The disabled user's still-unexpired token is refused
+	assert.Equal(t, http.StatusForbidden, call("GET", "/api/admin/users", models.RoleUser))
+	user, err := st.GetUserByID(ctx, ids[models.RoleUser])
+	require.NoError(t, err)
+	assert.NotNil(t, user.DisabledAt)
+}
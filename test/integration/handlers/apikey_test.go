@@ -0,0 +1,83 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"transaction-logger/internal/auth"
+	"transaction-logger/internal/handlers"
+	"transaction-logger/internal/store/memory"
+)
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	st := memory.New(0)
+	keys := handlers.NewAPIKeyHandler(st, nil)
+
+	req := withUser(httptest.NewRequest(http.MethodPost, "/api/api-keys",
+		strings.NewReader(`{"name": "batch", "scopes": ["transactions:read"]}`)), "user-1")
+	rec := httptest.NewRecorder()
+	keys.CreateAPIKey(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var created handlers.CreateAPIKeyResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&created))
+	assert.True(t, strings.HasPrefix(created.Key, created.Prefix))
+
+	// A protected endpoint behind the middleware, echoing the caller
+	protected := func(scope string) http.Handler {
+		return handlers.AuthMiddleware(nil, st)(handlers.RequireScope(scope, func(w http.ResponseWriter, r *http.Request) {
+			userID, _ := auth.UserFromContext(r.Context())
+			w.Write([]byte(userID))
+		}))
+	}
+	call := func(scope, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/transactions", nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		protected(scope).ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec = call(auth.ScopeTransactionsRead, created.Key)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "user-1", rec.Body.String())
+
+	assert.Equal(t, http.StatusForbidden, call(auth.ScopeTransactionsWrite, created.Key).Code)
+	assert.Equal(t, http.StatusUnauthorized, call(auth.ScopeTransactionsRead, "tlk_unknown").Code)
+
+	listed, err := st.ListAPIKeys(req.Context(), "user-1")
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.NotNil(t, listed[0].LastUsedAt)
+
+	// Revoked keys stop working
+	req = withUser(httptest.NewRequest(http.MethodDelete, "/api/api-keys/"+created.ID, nil), "user-1")
+	req = mux.SetURLVars(req, map[string]string{"id": created.ID})
+	rec = httptest.NewRecorder()
+	keys.RevokeAPIKey(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	assert.Equal(t, http.StatusUnauthorized, call(auth.ScopeTransactionsRead, created.Key).Code)
+}
+
+func TestCreateAPIKeyValidation(t *testing.T) {
+	keys := handlers.NewAPIKeyHandler(memory.New(0), nil)
+
+	req := withUser(httptest.NewRequest(http.MethodPost, "/api/api-keys",
+		strings.NewReader(`{"name": "batch", "scopes": ["everything"]}`)), "user-1")
+	rec := httptest.NewRecorder()
+	keys.CreateAPIKey(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	var resp handlers.Problem
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "scopes[0]", resp.Errors[0].Field)
+}
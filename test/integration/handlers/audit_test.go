@@ -0,0 +1,122 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"transaction-logger/internal/auth"
+	"transaction-logger/internal/config"
+	"transaction-logger/internal/handlers"
+	"transaction-logger/internal/mail"
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store/memory"
+)
+
+func TestAuditTrail(t *testing.T) {
+	require.NoError(t, auth.Init(&config.Config{}))
+	st := memory.New(0)
+	ctx := context.Background()
+
+	staff, err := models.NewUser("admin@example.com", "password123")
+	require.NoError(t, err)
+	staff.Role = models.RoleAdmin
+	require.NoError(t, st.CreateUser(ctx, staff))
+	adminToken, err := auth.GenerateJWT(staff.ID, staff.Email, staff.Role)
+	require.NoError(t, err)
+
+	auditor := handlers.NewAuditor(st)
+	h := handlers.NewAuthHandler(st, st, nil, mail.Links{}, nil, auditor)
+	admin := handlers.NewAdminHandler(st, st, st, st, nil, auditor)
+	router := mux.NewRouter()
+	router.HandleFunc("/api/auth/register", h.Register).Methods("POST")
+	router.HandleFunc("/api/auth/login", h.Login).Methods("POST")
+	api := router.PathPrefix("/api").Subrouter()
+	api.Use(handlers.AuthMiddleware(st, st))
+	api.HandleFunc("/audit", handlers.NewAuditHandler(st).List).Methods("GET")
+	api.HandleFunc("/admin/users/{id}/role", admin.SetRole).Methods("PUT")
+
+	call := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("User-Agent", "audit-test")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	list := func(query, token string) handlers.ListAuditEventsResponse {
+		rec := call("GET", "/api/audit"+query, token, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp handlers.ListAuditEventsResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		return resp
+	}
+	actions := func(events []models.AuditEvent) []string {
+		names := []string{}
+		for _, e := range events {
+			names = append(names, e.Action)
+		}
+		return names
+	}
+
+	rec := call("POST", "/api/auth/register", "", `{"email":"user@example.com","password":"password123"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var registered handlers.TokenResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&registered))
+	userID := registered.User.ID
+
+	call("POST", "/api/auth/login", "", `{"email":"user@example.com","password":"wrong-password"}`)
+	call("POST", "/api/auth/login", "", `{"email":"nobody@example.com","password":"password123"}`)
+	require.Equal(t, http.StatusOK, call("POST", "/api/auth/login", "", `{"email":"user@example.com","password":"password123"}`).Code)
+
+	// Users see what they did and what was done to them, newest first, but not
+	// failures against other addresses; asking for another user changes nothing
+	own := list("?user_id="+staff.ID, registered.Token)
+	assert.Equal(t, []string{models.AuditLogin, models.AuditLoginFailed, models.AuditRegister}, actions(own.Data))
+	assert.Equal(t, 3, own.Pagination.Total)
+
+	failed := own.Data[1]
+	assert.Equal(t, "bad_password", failed.Metadata["reason"])
+	assert.Empty(t, failed.ActorID)
+	assert.Equal(t, "audit-test", failed.UserAgent)
+	assert.NotEmpty(t, failed.IP)
+
+	// Once made an auditor, they can read other users' events
+	require.Equal(t, http.StatusOK, call("PUT", "/api/admin/users/"+userID+"/role", adminToken, `{"role":"auditor"}`).Code)
+	byStaff := list("?user_id="+staff.ID, registered.Token)
+	require.Len(t, byStaff.Data, 1)
+	role := byStaff.Data[0]
+	assert.Equal(t, models.AuditUserRole, role.Action)
+	assert.Equal(t, userID, role.TargetID)
+	assert.JSONEq(t, `"user"`, string(role.Changes["role"].Before))
+	assert.JSONEq(t, `"auditor"`, string(role.Changes["role"].After))
+
+	// Staff see every event and can filter by area
+	all := list("?action=auth&page_size=2", adminToken)
+	assert.Equal(t, 4, all.Pagination.Total)
+	assert.True(t, all.Pagination.HasMore)
+	unknown := list("?action="+models.AuditLoginFailed, adminToken)
+	require.Len(t, unknown.Data, 2)
+	assert.Equal(t, "nobody@example.com", unknown.Data[0].Metadata["email"])
+
+	assert.Equal(t, http.StatusBadRequest, call("GET", "/api/audit?from=yesterday", adminToken, "").Code)
+
+	// Back to a regular user, they see what staff did to them but not from where
+	require.Equal(t, http.StatusOK, call("PUT", "/api/admin/users/"+userID+"/role", adminToken, `{"role":"user"}`).Code)
+	own = list("", registered.Token)
+	demoted := own.Data[0]
+	assert.Equal(t, models.AuditUserRole, demoted.Action)
+	assert.Equal(t, staff.ID, demoted.ActorID)
+	assert.Empty(t, demoted.IP)
+	assert.Empty(t, demoted.UserAgent)
+	assert.Equal(t, "audit-test", own.Data[2].UserAgent, "their own login keeps its user agent")
+}
@@ -0,0 +1,273 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"transaction-logger/internal/auth"
+	"transaction-logger/internal/config"
+	"transaction-logger/internal/handlers"
+	"transaction-logger/internal/mail"
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store/memory"
+)
+
+func TestAuthMiddlewareStoresClaims(t *testing.T) {
+	require.NoError(t, auth.Init(&config.Config{}))
+	token, err := auth.GenerateJWT("user-1", "user@example.com", "user")
+	require.NoError(t, err)
+
+	var claims *auth.Claims
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ = auth.ClaimsFromContext(r.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/transactions", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	handlers.AuthMiddleware(nil, nil)(next).ServeHTTP(httptest.NewRecorder(), req)
+
+	require.NotNil(t, claims)
+	assert.Equal(t, "user-1", claims.UserID)
+	assert.Equal(t, "user@example.com", claims.Email)
+}
+
+func TestHandlersRejectMissingIdentity(t *testing.T) {
+	h := handlers.NewTransactionHandler(memory.New(0), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/transactions", nil)
+	rec := httptest.NewRecorder()
+	h.GetTransactions(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	require.NoError(t, auth.Init(&config.Config{}))
+	st := memory.New(0)
+	auth.SetDenylist(st)
+	defer auth.SetDenylist(nil)
+	h := handlers.NewAuthHandler(st, st, nil, mail.Links{}, nil, nil)
+
+	post := func(handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+	decode := func(rec *httptest.ResponseRecorder) handlers.TokenResponse {
+		var resp handlers.TokenResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		return resp
+	}
+
+	rec := post(h.Register, `{"email": "user@example.com", "password": "password123"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	first := decode(rec)
+	assert.NotEmpty(t, first.RefreshToken)
+
+	rec = post(h.Refresh, `{"refresh_token": "`+first.RefreshToken+`"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	second := decode(rec)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+
+	// Replaying the rotated token revokes the family, including the newer token
+	rec = post(h.Refresh, `{"refresh_token": "`+first.RefreshToken+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = post(h.Refresh, `{"refresh_token": "`+second.RefreshToken+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// Logout denylists the access token
+	claims, err := auth.ValidateToken(second.Token)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/logout", nil)
+	rec = httptest.NewRecorder()
+	h.Logout(rec, req.WithContext(auth.WithClaims(req.Context(), claims)))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	_, err = auth.ValidateToken(second.Token)
+	assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+}
+
+// outbox is a mail.Mailer that hands sent messages to the test
+type outbox chan mail.Message
+
+func (o outbox) Send(ctx context.Context, msg mail.Message) error {
+	o <- msg
+	return nil
+}
+
+var linkPattern = regexp.MustCompile(`https?://\S+`)
+
+// nextToken waits for the next message to reach to and returns the token in its link
+func (o outbox) nextToken(t *testing.T, to string) string {
+	t.Helper()
+	select {
+	case msg := <-o:
+		require.Equal(t, to, msg.To)
+		link, err := url.Parse(linkPattern.FindString(msg.Body))
+		require.NoError(t, err)
+		return link.Query().Get("token")
+	case <-time.After(time.Second):
+		t.Fatal("no email sent")
+		return ""
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	require.NoError(t, auth.Init(&config.Config{}))
+	st := memory.New(0)
+	mails := make(outbox, 1)
+	h := handlers.NewAuthHandler(st, st, mails, mail.Links{BaseURL: "https://app.example.com"}, nil, nil)
+
+	post := func(handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	rec := post(h.Register, `{"email": "user@example.com", "password": "password123"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var session handlers.TokenResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&session))
+	assert.Nil(t, session.User.EmailVerifiedAt)
+
+	// Registration sends a verification link that works once
+	verify := `{"token": "` + mails.nextToken(t, "user@example.com") + `"}`
+	assert.Equal(t, http.StatusNoContent, post(h.VerifyEmail, verify).Code)
+	assert.Equal(t, http.StatusBadRequest, post(h.VerifyEmail, verify).Code)
+	user, err := st.GetUserByEmail(context.Background(), "user@example.com")
+	require.NoError(t, err)
+	assert.NotNil(t, user.EmailVerifiedAt)
+
+	// Unknown addresses get the same answer and no email
+	assert.Equal(t, http.StatusAccepted, post(h.ForgotPassword, `{"email": "nobody@example.com"}`).Code)
+	assert.Equal(t, http.StatusAccepted, post(h.ForgotPassword, `{"email": "user@example.com"}`).Code)
+	reset := mails.nextToken(t, "user@example.com")
+
+	// The token works once and only as given
+	assert.Equal(t, http.StatusBadRequest, post(h.ResetPassword, `{"token": "`+reset+`x", "password": "new-password"}`).Code)
+	assert.Equal(t, http.StatusNoContent, post(h.ResetPassword, `{"token": "`+reset+`", "password": "new-password"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(h.ResetPassword, `{"token": "`+reset+`", "password": "other-password"}`).Code)
+
+	// The old password and the old session are gone
+	assert.Equal(t, http.StatusUnauthorized, post(h.Login, `{"email": "user@example.com", "password": "password123"}`).Code)
+	assert.Equal(t, http.StatusOK, post(h.Login, `{"email": "user@example.com", "password": "new-password"}`).Code)
+	assert.Equal(t, http.StatusUnauthorized, post(h.Refresh, `{"refresh_token": "`+session.RefreshToken+`"}`).Code)
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	require.NoError(t, auth.Init(&config.Config{}))
+	st := memory.New(0)
+	auth.SetDenylist(st)
+	defer auth.SetDenylist(nil)
+	h := handlers.NewAuthHandler(st, st, nil, mail.Links{}, nil, nil)
+	mfa := handlers.NewMFAHandler(st, st, st, nil, nil)
+
+	user, err := models.NewUser("user@example.com", "password123")
+	require.NoError(t, err)
+	require.NoError(t, st.CreateUser(context.Background(), user))
+
+	post := func(handler http.HandlerFunc, body string, claims *auth.Claims) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth", strings.NewReader(body))
+		if claims != nil {
+			req = req.WithContext(auth.WithClaims(req.Context(), claims))
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+	session := &auth.Claims{UserID: user.ID}
+	login := `{"email": "user@example.com", "password": "password123"}`
+
+	// Enrollment only takes effect once a code is confirmed
+	rec := post(mfa.EnrollTOTP, "", session)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var enrollment models.TOTPEnrollment
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&enrollment))
+	assert.Contains(t, post(h.Login, login, nil).Body.String(), `"refresh_token"`)
+
+	code, err := auth.GenerateTOTPCode(enrollment.Secret, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, post(mfa.ConfirmTOTP, `{"code": "000000x"}`, session).Code)
+	rec = post(mfa.ConfirmTOTP, `{"code": "`+code+`"}`, session)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var recovery handlers.RecoveryCodesResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&recovery))
+	require.Len(t, recovery.RecoveryCodes, models.RecoveryCodeCount)
+
+	// The password alone now only yields a challenge
+	rec = post(h.Login, login, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var challenge handlers.MFAChallengeResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&challenge))
+	assert.True(t, challenge.MFARequired)
+	_, err = auth.ValidateToken(challenge.MFAToken)
+	assert.Error(t, err, "a challenge must not work as an access token")
+
+	// The code used to confirm enrollment cannot be replayed
+	verify := func(code string) *httptest.ResponseRecorder {
+		return post(mfa.Verify, `{"mfa_token": "`+challenge.MFAToken+`", "code": "`+code+`"}`, nil)
+	}
+	assert.Equal(t, http.StatusUnauthorized, verify(code).Code)
+
+	// A recovery code works once, and so does the challenge
+	rec = verify(recovery.RecoveryCodes[0])
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var tokens handlers.TokenResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&tokens))
+	_, err = auth.ValidateToken(tokens.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, verify(recovery.RecoveryCodes[1]).Code)
+
+	require.NoError(t, json.NewDecoder(post(h.Login, login, nil).Body).Decode(&challenge))
+	assert.Equal(t, http.StatusUnauthorized, verify(recovery.RecoveryCodes[0]).Code)
+	assert.Equal(t, http.StatusOK, verify(strings.ToUpper(recovery.RecoveryCodes[1])).Code)
+}
+
+func TestLoginLockout(t *testing.T) {
+	require.NoError(t, auth.Init(&config.Config{}))
+	st := memory.New(0)
+	limiter := handlers.NewLoginLimiter(st, handlers.LockoutPolicy{Threshold: 1, Lockout: time.Minute}, handlers.LockoutPolicy{})
+	h := handlers.NewAuthHandler(st, st, nil, mail.Links{}, limiter, nil)
+	admin := handlers.NewAdminHandler(st, st, st, st, limiter, nil)
+
+	user, err := models.NewUser("user@example.com", "password123")
+	require.NoError(t, err)
+	require.NoError(t, st.CreateUser(context.Background(), user))
+
+	login := func(email, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login",
+			strings.NewReader(`{"email": "`+email+`", "password": "`+password+`"}`))
+		rec := httptest.NewRecorder()
+		h.Login(rec, req)
+		return rec
+	}
+
+	// Once locked, even the right password is refused until the lock ends
+	assert.Equal(t, http.StatusUnauthorized, login("user@example.com", "wrong-password").Code)
+	rec := login("user@example.com", "password123")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+
+	// Unknown emails are locked the same way, so lockouts do not reveal accounts
+	assert.Equal(t, http.StatusUnauthorized, login("nobody@example.com", "wrong-password").Code)
+	assert.Equal(t, http.StatusTooManyRequests, login("nobody@example.com", "wrong-password").Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/users/"+user.ID+"/unlock", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": user.ID})
+	admin.UnlockUser(httptest.NewRecorder(), req)
+	assert.Equal(t, http.StatusOK, login("user@example.com", "password123").Code)
+}
@@ -0,0 +1,110 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"transaction-logger/internal/auth"
+	"transaction-logger/internal/config"
+	"transaction-logger/internal/handlers"
+	"transaction-logger/internal/mail"
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store/memory"
+)
+
+func TestOrganizationMembership(t *testing.T) {
+	require.NoError(t, auth.Init(&config.Config{}))
+	st := memory.New(0)
+	ctx := context.Background()
+
+	tokens := map[string]string{}
+	users := map[string]*models.User{}
+	for _, name := range []string{"owner", "member", "outsider"} {
+		user, err := models.NewUser(name+"@example.com", "password123")
+		require.NoError(t, err)
+		require.NoError(t, st.CreateUser(ctx, user))
+		token, err := auth.GenerateJWT(user.ID, user.Email, user.Role)
+		require.NoError(t, err)
+		tokens[name], users[name] = token, user
+	}
+
+	mails := make(outbox, 1)
+	orgs := handlers.NewOrganizationHandler(st, st, mails, mail.Links{BaseURL: "https://app.example.com"}, nil)
+	transactions := handlers.NewTransactionHandler(st, nil)
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api").Subrouter()
+	api.Use(handlers.AuthMiddleware(st, st))
+	books := api.NewRoute().Subrouter()
+	books.Use(handlers.OrganizationMiddleware(st, st))
+	books.HandleFunc("/transactions", transactions.GetTransactions).Methods("GET")
+	books.HandleFunc("/transactions", transactions.CreateTransaction).Methods("POST")
+	api.HandleFunc("/organizations/{id}/members", orgs.ListMembers).Methods("GET")
+	api.HandleFunc("/organizations/{id}/members/{user_id}", orgs.UpdateMember).Methods("PUT")
+	api.HandleFunc("/organizations/{id}/invitations", orgs.CreateInvitation).Methods("POST")
+	api.HandleFunc("/invitations/accept", orgs.AcceptInvitation).Methods("POST")
+
+	call := func(method, path, who, orgID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+tokens[who])
+		if orgID != "" {
+			req.Header.Set(handlers.OrganizationHeader, orgID)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	orgID := users["owner"].DefaultOrganizationID
+	orgPath := "/api/organizations/" + orgID
+	transfer := `{"sender_account":"a","receiver_account":"b","amount":"5.00","currency":"USD","transaction_type":"Transfer"}`
+
+	// The owner's default organization is used without a header
+	rec := call("POST", "/api/transactions", "owner", "", transfer)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var created models.Transaction
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&created))
+	assert.Equal(t, orgID, created.OrganizationID)
+
+	// Outsiders cannot see the organization at all
+	assert.Equal(t, http.StatusNotFound, call("GET", "/api/transactions", "outsider", orgID, "").Code)
+	assert.Equal(t, http.StatusNotFound, call("GET", orgPath+"/members", "outsider", "", "").Code)
+
+	// Invite as a viewer; the token is emailed, not returned
+	rec = call("POST", orgPath+"/invitations", "owner", "", `{"email":"member@example.com","role":"viewer"}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "token")
+	accept := `{"token":"` + mails.nextToken(t, "member@example.com") + `"}`
+
+	// Only the invited email can accept, once it is verified
+	assert.Equal(t, http.StatusForbidden, call("POST", "/api/invitations/accept", "outsider", "", accept).Code)
+	assert.Equal(t, http.StatusForbidden, call("POST", "/api/invitations/accept", "member", "", accept).Code)
+	require.NoError(t, st.SetEmailVerified(ctx, users["member"].ID, time.Now()))
+	assert.Equal(t, http.StatusOK, call("POST", "/api/invitations/accept", "member", "", accept).Code)
+	assert.Equal(t, http.StatusNotFound, call("POST", "/api/invitations/accept", "member", "", accept).Code)
+
+	// Viewers read the shared books but cannot write to them
+	rec = call("GET", "/api/transactions", "member", orgID, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), created.ID)
+	assert.Equal(t, http.StatusForbidden, call("POST", "/api/transactions", "member", orgID, transfer).Code)
+
+	// Promoted to member, they can; their own organization is unaffected
+	memberPath := orgPath + "/members/" + users["member"].ID
+	assert.Equal(t, http.StatusForbidden, call("PUT", memberPath, "member", "", `{"role":"admin"}`).Code)
+	assert.Equal(t, http.StatusOK, call("PUT", memberPath, "owner", "", `{"role":"member"}`).Code)
+	assert.Equal(t, http.StatusCreated, call("POST", "/api/transactions", "member", orgID, transfer).Code)
+	assert.Equal(t, http.StatusConflict, call("PUT", orgPath+"/members/"+users["owner"].ID, "owner", "", `{"role":"admin"}`).Code)
+
+	rec = call("GET", "/api/transactions", "member", "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), created.ID)
+}
@@ -0,0 +1,47 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"transaction-logger/internal/handlers"
+	"transaction-logger/internal/ratelimit"
+	"transaction-logger/internal/store/memory"
+)
+
+func TestRateLimit(t *testing.T) {
+	limit, err := ratelimit.Parse("2/1m")
+	require.NoError(t, err)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	handler := handlers.RateLimit(memory.New(0), "test", limit)(ok)
+	call := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := call("192.0.2.1:1234")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", rec.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "2;w=60", rec.Header().Get("RateLimit-Policy"))
+	assert.Equal(t, http.StatusNoContent, call("192.0.2.1:1234").Code)
+
+	// The bucket is empty until a token refills, every 30 seconds
+	rec = call("192.0.2.1:5678")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	retryAfter := rec.Header().Get("Retry-After")
+	assert.Contains(t, []string{"29", "30"}, retryAfter)
+
+	// Other callers have buckets of their own
+	assert.Equal(t, http.StatusNoContent, call("192.0.2.2:1234").Code)
+}
@@ -0,0 +1,56 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"transaction-logger/internal/handlers"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnroutedRequests(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/transactions", ok).Methods("GET")
+	api.HandleFunc("/transactions", ok).Methods("POST")
+	api.HandleFunc("/accounts", ok).Methods("GET")
+
+	unrouted := handlers.Unrouted(router)
+	for _, r := range []*mux.Router{router, api} {
+		r.NotFoundHandler = unrouted
+		r.MethodNotAllowedHandler = unrouted
+	}
+
+	tests := []struct {
+		method, path string
+		status       int
+		code, allow  string
+	}{
+		{"GET", "/api/transactions", http.StatusOK, "", ""},
+		{"DELETE", "/api/transactions", http.StatusMethodNotAllowed, handlers.CodeMethodNotAllowed, "GET, POST"},
+		{"GET", "/api/nope", http.StatusNotFound, handlers.CodeNotFound, ""},
+		{"GET", "/nope", http.StatusNotFound, handlers.CodeNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, tt.allow, rec.Header().Get("Allow"))
+			if tt.code == "" {
+				return
+			}
+			assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+			var resp handlers.Problem
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+			assert.Equal(t, tt.code, resp.Code)
+		})
+	}
+}
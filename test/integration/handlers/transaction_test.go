@@ -0,0 +1,248 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"transaction-logger/internal/auth"
+	"transaction-logger/internal/handlers"
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store/memory"
+)
+
+// withUser returns r as if AuthMiddleware had authenticated userID and
+// OrganizationMiddleware had selected an organization the user owns alone
+func withUser(r *http.Request, userID string) *http.Request {
+	ctx := auth.WithClaims(r.Context(), &auth.Claims{UserID: userID})
+	ctx = auth.WithOrganization(ctx, auth.Organization{ID: "org_" + userID, Role: models.OrgRoleOwner})
+	return r.WithContext(ctx)
+}
+
+func createTransaction(t *testing.T, h *handlers.TransactionHandler, userID, body string) models.Transaction {
+	t.Helper()
+
+	req := withUser(httptest.NewRequest(http.MethodPost, "/api/transactions", strings.NewReader(body)), userID)
+	rec := httptest.NewRecorder()
+	h.CreateTransaction(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var tx models.Transaction
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&tx))
+	return tx
+}
+
+func TestTransactionLifecycle(t *testing.T) {
+	st := memory.New(0)
+	h := handlers.NewTransactionHandler(st, nil)
+
+	created := createTransaction(t, h, "user-1", `{
+		"sender_account": "ACC123456", "receiver_account": "ACC789012",
+		"amount": "100.50", "currency": "USD", "transaction_type": "Transfer"
+	}`)
+	assert.Equal(t, models.StatusPending, created.Status)
+	assert.Equal(t, models.Money(10050), created.Amount)
+
+	t.Run("get", func(t *testing.T) {
+		req := withUser(httptest.NewRequest(http.MethodGet, "/api/transactions/"+created.ID, nil), "user-1")
+		req = mux.SetURLVars(req, map[string]string{"id": created.ID})
+		rec := httptest.NewRecorder()
+		h.GetTransaction(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got models.Transaction
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+		assert.Equal(t, created.ID, got.ID)
+	})
+
+	t.Run("other users cannot see it", func(t *testing.T) {
+		req := withUser(httptest.NewRequest(http.MethodGet, "/api/transactions/"+created.ID, nil), "user-2")
+		req = mux.SetURLVars(req, map[string]string{"id": created.ID})
+		rec := httptest.NewRecorder()
+		h.GetTransaction(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		req := withUser(httptest.NewRequest(http.MethodGet, "/api/transactions?currency=USD", nil), "user-1")
+		rec := httptest.NewRecorder()
+		h.GetTransactions(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var resp handlers.GetTransactionsResponse
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, 1, resp.Pagination.Total)
+		assert.Len(t, resp.Data, 1)
+	})
+
+	t.Run("void", func(t *testing.T) {
+		req := withUser(httptest.NewRequest(http.MethodPost, "/api/transactions/"+created.ID+"/void", nil), "user-1")
+		req = mux.SetURLVars(req, map[string]string{"id": created.ID})
+		rec := httptest.NewRecorder()
+		h.VoidTransaction(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got models.Transaction
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+		assert.Equal(t, models.StatusCancelled, got.Status)
+	})
+
+	t.Run("advance", func(t *testing.T) {
+		account := models.NewAccount("org_user-1", "user-1", "Savings", "USD")
+		require.NoError(t, st.CreateAccount(context.Background(), account))
+		balance := func() models.Money {
+			b, err := st.AccountBalance(context.Background(), account, time.Now())
+			require.NoError(t, err)
+			return b.Balance
+		}
+
+		tx := createTransaction(t, h, "user-1", `{
+			"sender_account": "ACC123456", "receiver_account": "`+account.ID+`",
+			"amount": "20.00", "currency": "USD", "transaction_type": "Transfer", "status": "Completed"
+		}`)
+		assert.Equal(t, models.StatusPending, tx.Status, "transactions are always created Pending")
+		assert.Equal(t, models.Money(2000), balance())
+		advance := func(action, body string) *httptest.ResponseRecorder {
+			req := withUser(httptest.NewRequest(http.MethodPost, "/api/transactions/"+tx.ID+"/"+action, strings.NewReader(body)), "user-1")
+			req = mux.SetURLVars(req, map[string]string{"id": tx.ID, "action": action})
+			rec := httptest.NewRecorder()
+			h.AdvanceTransaction(rec, req)
+			return rec
+		}
+
+		assert.Equal(t, http.StatusOK, advance("authorize", "").Code)
+		assert.Equal(t, http.StatusOK, advance("complete", "").Code)
+
+		// Completed transactions can only be reversed
+		rec := advance("cancel", "")
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		var problem handlers.Problem
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&problem))
+		assert.Equal(t, handlers.CodeInvalidTransition, problem.Code)
+		assert.Contains(t, problem.Detail, "can only change to Reversed")
+
+		require.Equal(t, http.StatusOK, advance("reverse", `{"reason": "customer refund"}`).Code)
+		assert.Equal(t, models.Money(0), balance())
+		assert.Equal(t, http.StatusConflict, advance("complete", "").Code)
+
+		req := withUser(httptest.NewRequest(http.MethodGet, "/api/transactions/"+tx.ID+"/history", nil), "user-1")
+		req = mux.SetURLVars(req, map[string]string{"id": tx.ID})
+		rec = httptest.NewRecorder()
+		h.GetTransactionHistory(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var history struct {
+			Data []models.StatusChange `json:"data"`
+		}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&history))
+		require.Len(t, history.Data, 4)
+		assert.Empty(t, history.Data[0].From, "the first entry is the status it was created with")
+		assert.Equal(t, models.StatusPending, history.Data[0].To)
+		assert.Equal(t, models.StatusAuthorized, history.Data[2].From)
+		assert.Equal(t, models.StatusCompleted, history.Data[2].To)
+		assert.Equal(t, models.StatusReversed, history.Data[3].To)
+		assert.Equal(t, "customer refund", history.Data[3].Reason)
+		assert.Equal(t, "user-1", history.Data[3].UserID)
+		assert.False(t, history.Data[3].At.IsZero())
+	})
+}
+
+func TestCreateTransactionIdempotency(t *testing.T) {
+	h := handlers.NewTransactionHandler(memory.New(time.Hour), nil)
+	body := `{
+		"sender_account": "ACC123456", "receiver_account": "ACC789012",
+		"amount": "5.00", "currency": "EUR", "transaction_type": "Deposit"
+	}`
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req := withUser(httptest.NewRequest(http.MethodPost, "/api/transactions", strings.NewReader(body)), "user-1")
+		req.Header.Set("Idempotency-Key", "key-1")
+		rec := httptest.NewRecorder()
+		h.CreateTransaction(rec, req)
+		return rec
+	}
+
+	first := send(body)
+	require.Equal(t, http.StatusCreated, first.Code)
+	var created models.Transaction
+	require.NoError(t, json.Unmarshal(first.Body.Bytes(), &created))
+	assert.Equal(t, int64(1), created.ChainSeq)
+	assert.NotEmpty(t, created.Hash)
+
+	replay := send(body)
+	assert.Equal(t, http.StatusCreated, replay.Code)
+	assert.Equal(t, "true", replay.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, first.Body.String(), replay.Body.String())
+
+	mismatch := send(strings.Replace(body, "5.00", "6.00", 1))
+	assert.Equal(t, http.StatusUnprocessableEntity, mismatch.Code)
+}
+
+func TestGenerateSampleTransactionsRecordsHistory(t *testing.T) {
+	st := memory.New(0)
+	h := handlers.NewTransactionHandler(st, nil)
+
+	req := withUser(httptest.NewRequest(http.MethodPost, "/api/transactions/generatesample", nil), "user-1")
+	rec := httptest.NewRecorder()
+	h.GenerateSampleTransactions(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	samples, _, err := st.ListTransactions(context.Background(), "org_user-1", models.TransactionFilter{}, 100, 0)
+	require.NoError(t, err)
+	require.Len(t, samples, 100)
+	for _, tx := range samples {
+		history, err := st.ListStatusHistory(context.Background(), "org_user-1", tx.ID)
+		require.NoError(t, err)
+		require.NotEmpty(t, history)
+		assert.Empty(t, history[0].From)
+		for i := 1; i < len(history); i++ {
+			assert.True(t, models.CanTransition(history[i].From, history[i].To), "%s -> %s", history[i].From, history[i].To)
+		}
+		assert.Equal(t, tx.Status, history[len(history)-1].To)
+	}
+}
+
+func TestImportTransactionsMalformedCSV(t *testing.T) {
+	h := handlers.NewTransactionHandler(memory.New(0), nil)
+	header := "timestamp,sender_account,receiver_account,amount,currency,transaction_type,status\n"
+	valid := "2026-01-02,ACC123456,ACC789012,10.00,USD,Transfer,Completed\n"
+
+	tests := []struct {
+		name     string
+		body     string
+		accepted int
+		rejected []int
+	}{
+		{"bare quote", header + valid + "2026-01-02,AC\"C1,ACC789012,1.00,USD,Transfer,Completed\n" + valid, 2, []int{3}},
+		{"unterminated quote", header + valid + "\"unterminated\n", 1, []int{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withUser(httptest.NewRequest(http.MethodPost, "/api/transactions/import", strings.NewReader(tt.body)), "user-1")
+			rec := httptest.NewRecorder()
+			h.ImportTransactions(rec, req)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+			var report handlers.ImportReport
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+			assert.Equal(t, tt.accepted, report.Accepted)
+			var rejected []int
+			for _, row := range report.Rows {
+				if row.Status == "rejected" {
+					rejected = append(rejected, row.Line)
+				}
+			}
+			assert.Equal(t, tt.rejected, rejected)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"transaction-logger/internal/handlers"
+	"transaction-logger/internal/mail"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterValidation(t *testing.T) {
+	// Validation runs before any database access, so no database is needed
+	h := handlers.NewAuthHandler(nil, nil, nil, mail.Links{}, nil, nil)
+
+	tests := []struct {
+		name   string
+		body   string
+		fields map[string]string
+	}{
+		{
+			"missing fields",
+			`{}`,
+			map[string]string{"email": "is required", "password": "is required"},
+		},
+		{
+			"invalid email and short password",
+			`{"email": "not-an-email", "password": "short"}`,
+			map[string]string{"email": "must be a valid email address", "password": "must be at least 8 characters"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			h.Register(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+
+			var resp handlers.Problem
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+			assert.Equal(t, handlers.CodeValidationFailed, resp.Code)
+
+			got := map[string]string{}
+			for _, f := range resp.Errors {
+				got[f.Field] = f.Message
+			}
+			assert.Equal(t, tt.fields, got)
+		})
+	}
+}
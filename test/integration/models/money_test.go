@@ -0,0 +1,86 @@
+package models_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"transaction-logger/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    models.Money
+		wantErr bool
+	}{
+		{"150.75", 15075, false},
+		{"0.1", 10, false},
+		{"3", 300, false},
+		{"-2.5", -250, false},
+		{".5", 50, false},
+		{"1.005", 0, true},
+		{"1e3", 0, true},
+		{"+5", 500, false},
+		{"-+5", 0, true},
+		{"+-5", 0, true},
+		{"--5", 0, true},
+		{"", 0, true},
+		{"10000000000000", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := models.ParseMoney(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	m, err := models.ParseAmount("19.99", "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, models.Money(1999), m)
+
+	_, err = models.ParseAmount("19.999", "USD")
+	assert.EqualError(t, err, "invalid amount: at most 2 decimal places allowed")
+}
+
+func TestMoneyArithmeticIsExact(t *testing.T) {
+	a, _ := models.ParseMoney("0.1")
+	b, _ := models.ParseMoney("0.2")
+	assert.Equal(t, "0.30", (a + b).String())
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var tx models.Transaction
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount": 19.99}`), &tx))
+	assert.Equal(t, models.Money(1999), tx.Amount)
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount": "-0.05"}`), &tx))
+	assert.Equal(t, models.Money(-5), tx.Amount)
+
+	out, err := json.Marshal(models.Money(1999))
+	assert.NoError(t, err)
+	assert.Equal(t, `"19.99"`, string(out))
+}
+
+func TestMoneyScan(t *testing.T) {
+	var m models.Money
+	assert.NoError(t, m.Scan([]byte("1234.56")))
+	assert.Equal(t, models.Money(123456), m)
+
+	v, err := m.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "1234.56", v)
+
+	assert.NoError(t, m.Scan(int64(42)))
+	assert.Equal(t, models.Money(4200), m)
+	assert.Error(t, m.Scan(int64(1)<<62), "whole amounts that overflow are rejected")
+}
@@ -0,0 +1,45 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"transaction-logger/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionCursorRoundTrip(t *testing.T) {
+	ts := time.Date(2025, 5, 23, 18, 57, 45, 123456000, time.UTC)
+	c := models.NewTransactionCursor(models.Transaction{ID: "TXN1", Timestamp: ts}, models.CursorNext)
+
+	decoded, err := models.DecodeTransactionCursor(c.Encode())
+	assert.NoError(t, err)
+	assert.Equal(t, "TXN1", decoded.ID)
+	assert.True(t, ts.Equal(decoded.Timestamp))
+	assert.True(t, decoded.Forward())
+
+	for _, bad := range []string{"not-base64!", "e30", "eyJpZCI6IngiLCJkIjoic2lkZXdheXMifQ"} {
+		_, err := models.DecodeTransactionCursor(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestTransactionCursorSeek(t *testing.T) {
+	var first *models.TransactionCursor
+	where, orderBy, args := first.Seek(true, 1)
+	assert.Empty(t, where)
+	assert.Equal(t, " ORDER BY timestamp DESC, id DESC", orderBy)
+	assert.Empty(t, args)
+
+	next := &models.TransactionCursor{ID: "TXN1", Direction: models.CursorNext}
+	where, orderBy, args = next.Seek(true, 3)
+	assert.Equal(t, " AND (timestamp, id) < ($4, $5)", where)
+	assert.Equal(t, " ORDER BY timestamp DESC, id DESC", orderBy)
+	assert.Len(t, args, 2)
+
+	prev := &models.TransactionCursor{ID: "TXN1", Direction: models.CursorPrev}
+	where, orderBy, _ = prev.Seek(true, 1)
+	assert.Equal(t, " AND (timestamp, id) > ($2, $3)", where)
+	assert.Equal(t, " ORDER BY timestamp ASC, id ASC", orderBy)
+}
@@ -0,0 +1,68 @@
+package models_test
+
+import (
+	"net/url"
+	"testing"
+
+	"transaction-logger/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTransactionFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		where   string
+		args    int
+		orderBy string
+		wantErr bool
+	}{
+		{"defaults", "", "", 0, " ORDER BY timestamp DESC, id DESC", false},
+		{
+			"date range and amounts",
+			"from=2025-01-01&to=2025-01-31&min_amount=10&max_amount=99.99",
+			" AND timestamp >= $2 AND timestamp <= $3 AND amount >= $4 AND amount <= $5",
+			4, " ORDER BY timestamp DESC, id DESC", false,
+		},
+		{
+			"exact and prefix accounts",
+			"currency=USD&sender_account=123&receiver_account_prefix=45",
+			" AND currency = $2 AND sender_account = $3 AND receiver_account LIKE $4 ESCAPE '\\'",
+			3, " ORDER BY timestamp DESC, id DESC", false,
+		},
+		{"ascending sort", "sort=amount", "", 0, " ORDER BY amount ASC, id ASC", false},
+		{"descending sort", "sort=-status", "", 0, " ORDER BY status DESC, id DESC", false},
+		{"unknown sort column", "sort=password", "", 0, "", true},
+		{"invalid date", "from=yesterday", "", 0, "", true},
+		{"invalid amount", "min_amount=ten", "", 0, "", true},
+		{"inverted range", "min_amount=10&max_amount=5", "", 0, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			assert.NoError(t, err)
+
+			f, err := models.ParseTransactionFilter(q)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			where, args := f.Where(1)
+			assert.Equal(t, tt.where, where)
+			assert.Len(t, args, tt.args)
+			assert.Equal(t, tt.orderBy, f.OrderBy())
+		})
+	}
+}
+
+func TestTransactionFilterEscapesPrefix(t *testing.T) {
+	f, err := models.ParseTransactionFilter(url.Values{"sender_account_prefix": {"12%_"}})
+	assert.NoError(t, err)
+
+	_, args := f.Where(1)
+	assert.Equal(t, []interface{}{`12\%\_%`}, args)
+}
@@ -0,0 +1,47 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"transaction-logger/internal/ratelimit"
+)
+
+func TestParse(t *testing.T) {
+	limit, err := ratelimit.Parse("100/1m")
+	require.NoError(t, err)
+	assert.Equal(t, ratelimit.Limit{Requests: 100, Per: time.Minute}, limit)
+
+	for _, disabled := range []string{"", "0", " 0/1m"} {
+		limit, err := ratelimit.Parse(disabled)
+		require.NoError(t, err)
+		assert.False(t, limit.Enabled(), disabled)
+	}
+	for _, invalid := range []string{"100", "x/1m", "100/x", "100/-1s", "-1/1m"} {
+		_, err := ratelimit.Parse(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestBucketRefills(t *testing.T) {
+	limit := ratelimit.Limit{Requests: 10, Per: 10 * time.Second}
+	start := time.Now()
+	bucket := ratelimit.NewBucket(limit, start)
+	for i := 0; i < 10; i++ {
+		require.True(t, bucket.Take(limit, start).Allowed)
+	}
+	res := bucket.Take(limit, start)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+	assert.Equal(t, 10*time.Second, res.Reset)
+
+	// Tokens refill at one a second and never beyond the limit
+	assert.True(t, bucket.Take(limit, start.Add(1500*time.Millisecond)).Allowed)
+	assert.False(t, bucket.Take(limit, start.Add(1500*time.Millisecond)).Allowed)
+	res = bucket.Take(limit, start.Add(time.Hour))
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 9, res.Remaining)
+}
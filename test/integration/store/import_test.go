@@ -0,0 +1,50 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+	"transaction-logger/internal/store/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportTransitionsCommitTogether(t *testing.T) {
+	ctx := context.Background()
+	st := memory.New(0)
+	tx := models.Transaction{
+		ID: "tx-1", Timestamp: time.Now(), SenderAccount: "a", ReceiverAccount: "b",
+		Amount: 100, Currency: "USD", TransactionType: "Transfer", Status: models.StatusPending,
+		OrganizationID: "org-1", UserID: "user-1",
+	}
+	change := func(from, to string) models.StatusChange {
+		return models.StatusChange{TransactionID: tx.ID, OrganizationID: tx.OrganizationID, UserID: tx.UserID, From: from, To: to, At: time.Now()}
+	}
+
+	// A change that does not follow the staged status fails the whole batch
+	batch, err := st.BeginImport(ctx)
+	require.NoError(t, err)
+	require.NoError(t, batch.Insert(ctx, []models.Transaction{tx}))
+	require.NoError(t, batch.Transition(ctx, change(models.StatusAuthorized, models.StatusCompleted)))
+	assert.ErrorIs(t, batch.Commit(), store.ErrConflict)
+	_, err = st.GetTransaction(ctx, tx.OrganizationID, tx.ID)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	batch, err = st.BeginImport(ctx)
+	require.NoError(t, err)
+	require.NoError(t, batch.Insert(ctx, []models.Transaction{tx}))
+	require.NoError(t, batch.Transition(ctx, change(models.StatusPending, models.StatusCompleted)))
+	require.NoError(t, batch.Transition(ctx, change(models.StatusCompleted, models.StatusReversed)))
+	require.NoError(t, batch.Commit())
+
+	got, err := st.GetTransaction(ctx, tx.OrganizationID, tx.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusReversed, got.Status)
+	history, err := st.ListStatusHistory(ctx, tx.OrganizationID, tx.ID)
+	require.NoError(t, err)
+	assert.Len(t, history, 3)
+}
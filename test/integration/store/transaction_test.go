@@ -0,0 +1,33 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+	"transaction-logger/internal/store/memory"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateTransactionRejectsDuplicateIDs(t *testing.T) {
+	ctx := context.Background()
+	st := memory.New(0)
+	tx := models.Transaction{
+		ID: "tx-1", Timestamp: time.Now(), SenderAccount: "a", ReceiverAccount: "b",
+		Amount: 100, Currency: "USD", TransactionType: "Transfer", Status: models.StatusPending,
+		OrganizationID: "org-1", UserID: "user-1",
+	}
+
+	_, err := st.CreateTransaction(ctx, &tx, nil)
+	assert.NoError(t, err)
+	again := tx
+	_, err = st.CreateTransaction(ctx, &again, nil)
+	assert.ErrorIs(t, err, store.ErrAlreadyExists)
+
+	history, err := st.ListStatusHistory(ctx, tx.OrganizationID, tx.ID)
+	assert.NoError(t, err)
+	assert.Len(t, history, 1, "the rejected copy records no history")
+}
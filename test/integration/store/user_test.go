@@ -1,9 +1,12 @@
-package models_test
+package store_test
 
 import (
+	"context"
 	"testing"
 
 	"transaction-logger/internal/models"
+	"transaction-logger/internal/store"
+	"transaction-logger/internal/store/postgres"
 	"transaction-logger/internal/testutils"
 
 	_ "github.com/lib/pq"
@@ -12,6 +15,7 @@ import (
 
 func TestCreateUser(t *testing.T) {
 	db := testutils.SetupTestDB(t)
+	users := postgres.New(db.DB, 0)
 
 	tests := []struct {
 		name     string
@@ -25,9 +29,12 @@ func TestCreateUser(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			user, err := models.CreateUser(db.DB, tt.email, tt.password)
+			user, err := models.NewUser(tt.email, tt.password)
+			assert.NoError(t, err)
+
+			err = users.CreateUser(context.Background(), user)
 			if tt.wantErr {
-				assert.Error(t, err)
+				assert.ErrorIs(t, err, store.ErrAlreadyExists)
 				return
 			}
 
@@ -41,12 +48,14 @@ func TestCreateUser(t *testing.T) {
 
 func TestGetUserByEmail(t *testing.T) {
 	db := testutils.SetupTestDB(t)
+	users := postgres.New(db.DB, 0)
 
 	// Create a test user
 	email := "test@example.com"
 	password := "password123"
-	_, err := models.CreateUser(db.DB, email, password)
+	user, err := models.NewUser(email, password)
 	assert.NoError(t, err)
+	assert.NoError(t, users.CreateUser(context.Background(), user))
 
 	tests := []struct {
 		name    string
@@ -59,9 +68,9 @@ func TestGetUserByEmail(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			user, err := models.GetUserByEmail(db.DB, tt.email)
+			user, err := users.GetUserByEmail(context.Background(), tt.email)
 			if tt.wantErr {
-				assert.Error(t, err)
+				assert.ErrorIs(t, err, store.ErrNotFound)
 				return
 			}
 